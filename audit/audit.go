@@ -0,0 +1,104 @@
+// Package audit appends to the audit log. Entries are written with the
+// transaction of the change they describe, so a change and its record
+// commit or roll back together. The actor and request ID travel in the
+// context given to gorm with WithContext.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/user"
+	"stocky/models"
+
+	"gorm.io/gorm"
+)
+
+// System is the actor for changes made by the service itself: startup
+// seeding, the price updater and the corporate action processor
+const System = "system"
+
+// CommandActor identifies the operating system user running a command-line
+// tool, e.g. "cli:bhavcopy:alice"
+func CommandActor(command string) string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return "cli:" + command + ":" + name
+}
+
+type contextKey int
+
+const (
+	actorKey contextKey = iota
+	requestIDKey
+)
+
+// WithActor returns a context recording actor as the one making changes
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// WithRequestID returns a context carrying the ID of the HTTP request
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// ActorFrom returns the actor in ctx, or System
+func ActorFrom(ctx context.Context) string {
+	if ctx != nil {
+		if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+			return actor
+		}
+	}
+	return System
+}
+
+// RequestIDFrom returns the request ID in ctx, if any
+func RequestIDFrom(ctx context.Context) string {
+	if ctx != nil {
+		if id, ok := ctx.Value(requestIDKey).(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// Record appends an entry describing a change to an entity, in tx's
+// transaction. before and after are stored as JSON; pass nil for an entity
+// that didn't exist before or doesn't after.
+func Record(tx *gorm.DB, action, entityType string, entityID interface{}, before, after interface{}) error {
+	entry := models.AuditLog{
+		Actor:      ActorFrom(tx.Statement.Context),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   fmt.Sprint(entityID),
+		RequestID:  RequestIDFrom(tx.Statement.Context),
+	}
+	var err error
+	if entry.Before, err = marshal(before); err != nil {
+		return err
+	}
+	if entry.After, err = marshal(after); err != nil {
+		return err
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+func marshal(value interface{}) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit state: %w", err)
+	}
+	if string(data) == "null" {
+		return nil, nil
+	}
+	return data, nil
+}
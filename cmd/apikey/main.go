@@ -0,0 +1,59 @@
+// Command apikey issues an API key using the database settings from .env.
+// Use it to create the first admin key:
+//
+//	go run ./cmd/apikey -name alice -roles ops
+//
+// The key is printed once and can't be recovered; only its hash is stored.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"stocky/audit"
+	"stocky/config"
+	"stocky/database"
+	"stocky/services"
+
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	name := flag.String("name", "", "name identifying the key's owner")
+	scopes := flag.String("scopes", "", "comma-separated partner scopes: rewards:write, users:read, prices:read")
+	roles := flag.String("roles", "", "comma-separated admin roles: ops, finance, auditor")
+	flag.Parse()
+	if *name == "" || (*scopes == "" && *roles == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := config.LoadConfig()
+	db, err := database.Connect(cfg)
+	if err != nil {
+		logrus.Fatalf("Failed to connect to database: %v", err)
+	}
+	if err := database.RunMigrations(db); err != nil {
+		logrus.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	authService := services.NewAuthService(db, cfg)
+	apiKey, key, err := authService.CreateAPIKey(audit.WithActor(context.Background(), audit.CommandActor("apikey")), nil, *name, splitList(*scopes), splitList(*roles))
+	if err != nil {
+		logrus.Fatalf("Failed to create API key: %v", err)
+	}
+	fmt.Printf("API key %d (%s): %s\n", apiKey.ID, apiKey.Name, key)
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
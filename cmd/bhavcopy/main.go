@@ -0,0 +1,61 @@
+// Command bhavcopy loads end-of-day bhavcopy CSV files into the price tables
+// using the database settings from .env:
+//
+//	go run ./cmd/bhavcopy cm10NOV2025bhav.csv [more files...]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"stocky/audit"
+	"stocky/config"
+	"stocky/database"
+	"stocky/services"
+
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: bhavcopy FILE...")
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := config.LoadConfig()
+	db, err := database.Connect(cfg)
+	if err != nil {
+		logrus.Fatalf("Failed to connect to database: %v", err)
+	}
+	if err := database.RunMigrations(db); err != nil {
+		logrus.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	calendar, err := services.LoadMarketCalendar(cfg)
+	if err != nil {
+		logrus.Fatalf("Failed to load market calendar: %v", err)
+	}
+
+	// Importing never calls the price provider
+	priceService := services.NewStockPriceService(db, nil, cfg.PriceStaleAfter, calendar)
+
+	ctx := audit.WithActor(context.Background(), audit.CommandActor("bhavcopy"))
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			logrus.Fatalf("Failed to open %s: %v", path, err)
+		}
+		result, err := priceService.ImportBhavcopy(ctx, f)
+		f.Close()
+		if err != nil {
+			logrus.Fatalf("Failed to import %s: %v", path, err)
+		}
+		logrus.Infof("%s: imported %d prices for %v", path, result.Imported, result.TradeDates)
+	}
+}
@@ -0,0 +1,46 @@
+// Command mockprice serves hypothetical stock quotes over HTTP so the
+// http price provider can be exercised locally:
+//
+//	go run ./cmd/mockprice -addr :9090
+//	PRICE_PROVIDER=http PRICE_API_URL=http://localhost:9090 go run main.go
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"strings"
+
+	"stocky/services"
+
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	addr := flag.String("addr", ":9090", "listen address")
+	flag.Parse()
+
+	provider := services.NewRandomPriceProvider()
+
+	http.HandleFunc("/prices/", func(w http.ResponseWriter, r *http.Request) {
+		symbol := strings.ToUpper(strings.TrimPrefix(r.URL.Path, "/prices/"))
+		if symbol == "" {
+			http.Error(w, "symbol is required", http.StatusBadRequest)
+			return
+		}
+
+		price, err := provider.FetchPrice(symbol)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(services.PriceQuote{Symbol: symbol, Price: price})
+	})
+
+	logrus.Infof("Mock price server listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, nil); err != nil {
+		logrus.Fatalf("Mock price server failed: %v", err)
+	}
+}
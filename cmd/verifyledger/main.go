@@ -0,0 +1,46 @@
+// Command verifyledger walks the ledger hash chain and checks it against
+// every checkpoint, using the database and anchor file settings from .env:
+//
+//	go run ./cmd/verifyledger
+//
+// It prints the result as JSON and exits with status 1 if the chain is broken.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"stocky/config"
+	"stocky/database"
+	"stocky/models"
+	"stocky/services"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	cfg := config.LoadConfig()
+	db, err := database.Connect(cfg)
+	if err != nil {
+		logrus.Fatalf("Failed to connect to database: %v", err)
+	}
+	// Keep SQL logging off stdout, where the result goes
+	db.Logger = logger.Default.LogMode(logger.Silent)
+
+	ledgerService := services.NewLedgerService(db, cfg.LedgerAnchorFile)
+	result, err := ledgerService.VerifyChain(context.Background())
+	if err != nil {
+		logrus.Fatalf("Failed to verify ledger: %v", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		logrus.Fatalf("Failed to write result: %v", err)
+	}
+	if result.Status != models.LedgerChainOK {
+		os.Exit(1)
+	}
+}
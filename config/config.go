@@ -2,8 +2,10 @@ package config
 
 import (
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 )
 
@@ -14,6 +16,40 @@ type Config struct {
 	DBPassword string
 	DBName     string
 	ServerPort string
+
+	// How long shutdown waits for in-flight requests and background workers
+	ShutdownTimeout time.Duration
+
+	// Price provider settings
+	PriceProvider   string // random, file or http
+	PriceFile       string
+	PriceAPIURL     string
+	PriceAPITimeout time.Duration
+	PriceStaleAfter time.Duration // prices older than this are stale; 0 disables the check
+
+	// CSV imported into an empty securities master on startup
+	SecuritiesFile string
+
+	// Market calendar: exchange holidays and session hours (HH:MM, IST)
+	HolidaysFile string
+	MarketOpen   string
+	MarketClose  string
+
+	// End-user bearer tokens: HS256 JWTs signed with JWTSecret. Issuer and
+	// audience are checked when set; user tokens are refused if there is no secret.
+	JWTSecret   string
+	JWTIssuer   string
+	JWTAudience string
+
+	// Ledger hash chain checkpoints: how often one is taken (0 disables),
+	// and the file each is appended to so it survives outside the database
+	LedgerCheckpointInterval time.Duration
+	LedgerAnchorFile         string
+
+	// Dividend TDS: rate withheld once a user's dividends from one company
+	// in a financial year exceed the threshold (INR)
+	DividendTDSRate      decimal.Decimal
+	DividendTDSThreshold decimal.Decimal
 }
 
 func LoadConfig() *Config {
@@ -30,6 +66,30 @@ func LoadConfig() *Config {
 		DBPassword: getEnv("DB_PASSWORD", ""),
 		DBName:     getEnv("DB_NAME", "assignment"),
 		ServerPort: getEnv("SERVER_PORT", "8080"),
+
+		ShutdownTimeout: getDurationEnv("SHUTDOWN_TIMEOUT", 30*time.Second),
+
+		PriceProvider:   getEnv("PRICE_PROVIDER", "random"),
+		PriceFile:       getEnv("PRICE_FILE", ""),
+		PriceAPIURL:     getEnv("PRICE_API_URL", ""),
+		PriceAPITimeout: getDurationEnv("PRICE_API_TIMEOUT", 5*time.Second),
+		PriceStaleAfter: getDurationEnv("PRICE_STALE_AFTER", 2*time.Hour),
+
+		SecuritiesFile: getEnv("SECURITIES_FILE", "data/securities.csv"),
+
+		HolidaysFile: getEnv("HOLIDAYS_FILE", "data/holidays.csv"),
+		MarketOpen:   getEnv("MARKET_OPEN", "09:15"),
+		MarketClose:  getEnv("MARKET_CLOSE", "15:30"),
+
+		JWTSecret:   getEnv("JWT_SECRET", ""),
+		JWTIssuer:   getEnv("JWT_ISSUER", ""),
+		JWTAudience: getEnv("JWT_AUDIENCE", ""),
+
+		LedgerCheckpointInterval: getDurationEnv("LEDGER_CHECKPOINT_INTERVAL", time.Hour),
+		LedgerAnchorFile:         getEnv("LEDGER_ANCHOR_FILE", ""),
+
+		DividendTDSRate:      getDecimalEnv("DIVIDEND_TDS_RATE", "0.10"),
+		DividendTDSThreshold: getDecimalEnv("DIVIDEND_TDS_THRESHOLD", "10000"),
 	}
 }
 
@@ -40,3 +100,26 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logrus.Warnf("Invalid duration for %s: %q, using default %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+func getDecimalEnv(key string, defaultValue string) decimal.Decimal {
+	value := getEnv(key, defaultValue)
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		logrus.Warnf("Invalid number for %s: %q, using default %s", key, value, defaultValue)
+		return decimal.RequireFromString(defaultValue)
+	}
+	return d
+}
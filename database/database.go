@@ -37,19 +37,167 @@ func Connect(cfg *config.Config) (*gorm.DB, error) {
 	return db, nil
 }
 
+// Close closes the connection pool
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// migratedModels are the tables RunMigrations creates, in dependency order
+var migratedModels = []interface{}{
+	&models.StockReward{},
+	&models.Security{},
+	&models.IdempotencyRecord{},
+	&models.Campaign{},
+	&models.Account{},
+	&models.Journal{},
+	&models.LedgerEntry{},
+	&models.StockPrice{},
+	&models.PriceHistory{},
+	&models.DailyPrice{},
+	&models.CorporateAction{},
+	&models.Dividend{},
+	&models.DividendPayout{},
+	&models.FeeSchedule{},
+	&models.FeeComponent{},
+	&models.APIKey{},
+	&models.AuditLog{},
+	&models.LedgerCheckpoint{},
+}
+
 func RunMigrations(db *gorm.DB) error {
 	logrus.Info("Running database migrations...")
 
-	err := db.AutoMigrate(
-		&models.StockReward{},
-		&models.LedgerEntry{},
-		&models.StockPrice{},
-	)
+	err := db.AutoMigrate(migratedModels...)
 
 	if err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	// Seed price history from latest prices recorded before history existed
+	if err := db.Exec(`
+		INSERT INTO price_histories (stock_symbol, price, source, observed_at, created_at)
+		SELECT sp.stock_symbol, sp.price, 'legacy', sp.updated_at, NOW()
+		FROM stock_prices sp
+		WHERE NOT EXISTS (SELECT 1 FROM price_histories ph WHERE ph.stock_symbol = sp.stock_symbol)
+	`).Error; err != nil {
+		return fmt.Errorf("failed to backfill price history: %w", err)
+	}
+
+	// Ledger entries written before user_id existed take it from their reward
+	if err := db.Exec(`
+		UPDATE ledger_entries le SET user_id = sr.user_id
+		FROM stock_rewards sr
+		WHERE le.reward_id = sr.id AND (le.user_id IS NULL OR le.user_id = '')
+	`).Error; err != nil {
+		return fmt.Errorf("failed to backfill ledger user ids: %w", err)
+	}
+
+	// Keys used before idempotency records existed stay reserved; with no
+	// fingerprint stored they replay the reward for any payload
+	if err := db.Exec(`
+		INSERT INTO idempotency_records (key, fingerprint, reward_id, response_body, created_at)
+		SELECT sr.idempotency_key, '', sr.id, '', sr.created_at
+		FROM stock_rewards sr
+		WHERE sr.idempotency_key IS NOT NULL AND sr.idempotency_key <> ''
+		ON CONFLICT (key) DO NOTHING
+	`).Error; err != nil {
+		return fmt.Errorf("failed to backfill idempotency records: %w", err)
+	}
+
+	// Keys issued with the old all-powerful admin scope become ops and finance
+	if err := db.Exec(`
+		UPDATE api_keys SET roles = '["ops", "finance"]'::jsonb, scopes = scopes - 'admin'
+		WHERE scopes @> '["admin"]'::jsonb
+	`).Error; err != nil {
+		return fmt.Errorf("failed to convert admin API keys to roles: %w", err)
+	}
+
+	// The audit log is append-only, even for direct SQL
+	if err := db.Exec(`
+		CREATE OR REPLACE FUNCTION reject_append_only_change() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+		END;
+		$$ LANGUAGE plpgsql;
+
+		DROP TRIGGER IF EXISTS audit_logs_no_change ON audit_logs;
+		CREATE TRIGGER audit_logs_no_change BEFORE UPDATE OR DELETE ON audit_logs
+			FOR EACH ROW EXECUTE FUNCTION reject_append_only_change();
+
+		DROP TRIGGER IF EXISTS audit_logs_no_truncate ON audit_logs;
+		CREATE TRIGGER audit_logs_no_truncate BEFORE TRUNCATE ON audit_logs
+			FOR EACH STATEMENT EXECUTE FUNCTION reject_append_only_change();
+	`).Error; err != nil {
+		return fmt.Errorf("failed to protect audit log: %w", err)
+	}
+
+	// Ledger checkpoints are append-only in the same way
+	if err := db.Exec(`
+		DROP TRIGGER IF EXISTS ledger_checkpoints_no_change ON ledger_checkpoints;
+		CREATE TRIGGER ledger_checkpoints_no_change BEFORE UPDATE OR DELETE ON ledger_checkpoints
+			FOR EACH ROW EXECUTE FUNCTION reject_append_only_change();
+
+		DROP TRIGGER IF EXISTS ledger_checkpoints_no_truncate ON ledger_checkpoints;
+		CREATE TRIGGER ledger_checkpoints_no_truncate BEFORE TRUNCATE ON ledger_checkpoints
+			FOR EACH STATEMENT EXECUTE FUNCTION reject_append_only_change();
+	`).Error; err != nil {
+		return fmt.Errorf("failed to protect ledger checkpoints: %w", err)
+	}
+
 	logrus.Info("Database migrations completed successfully")
 	return nil
 }
+
+// PendingMigrations lists the tables and columns (as table.column) the
+// models expect but the database lacks. It is empty once RunMigrations has
+// run against the current models.
+func PendingMigrations(db *gorm.DB) ([]string, error) {
+	rows, err := db.Raw(`
+		SELECT table_name, column_name FROM information_schema.columns
+		WHERE table_schema = CURRENT_SCHEMA()
+	`).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		if existing[table] == nil {
+			existing[table] = make(map[string]bool)
+		}
+		existing[table][column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var pending []string
+	for _, model := range migratedModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		table := stmt.Schema.Table
+		columns, ok := existing[table]
+		if !ok {
+			pending = append(pending, table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.IgnoreMigration || columns[field.DBName] {
+				continue
+			}
+			pending = append(pending, table+"."+field.DBName)
+		}
+	}
+	return pending, nil
+}
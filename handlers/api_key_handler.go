@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"stocky/middleware"
+	"stocky/models"
+	"stocky/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type APIKeyHandler struct {
+	authService *services.AuthService
+}
+
+func NewAPIKeyHandler(authService *services.AuthService) *APIKeyHandler {
+	return &APIKeyHandler{authService: authService}
+}
+
+// CreateAPIKey issues a partner or admin API key. The key is only shown in this response.
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req models.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	apiKey, key, err := h.authService.CreateAPIKey(c.Request.Context(), middleware.CurrentPrincipal(c), req.Name, req.Scopes, req.Roles)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKeyRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrGrantNotHeld) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		logrus.Errorf("Failed to create API key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIKeyResponse{APIKey: *apiKey, Key: key})
+}
+
+// ListAPIKeys returns all API keys without their secrets
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.authService.ListAPIKeys()
+	if err != nil {
+		logrus.Errorf("Failed to list API keys: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API keys"})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey stops an API key from authenticating
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key id"})
+		return
+	}
+
+	apiKey, err := h.authService.RevokeAPIKey(c.Request.Context(), middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, services.ErrAPIKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if errors.Is(err, services.ErrGrantNotHeld) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to revoke API key %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+	c.JSON(http.StatusOK, apiKey)
+}
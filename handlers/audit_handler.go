@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"stocky/models"
+	"stocky/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// ListAuditLogs returns audit entries, newest first, filtered by ?actor=,
+// ?action=, ?entity_type=, ?entity_id=, ?request_id= and ?from=/?to=
+// (YYYY-MM-DD, both inclusive). Page with ?limit= and ?before_id=.
+func (h *AuditHandler) ListAuditLogs(c *gin.Context) {
+	filter := services.AuditFilter{
+		Actor:      c.Query("actor"),
+		Action:     c.Query("action"),
+		EntityType: c.Query("entity_type"),
+		EntityID:   c.Query("entity_id"),
+		RequestID:  c.Query("request_id"),
+	}
+
+	var ok bool
+	if filter.From, ok = dateQuery(c, "from", filter.From); !ok {
+		return
+	}
+	if filter.To, ok = dateQuery(c, "to", filter.To); !ok {
+		return
+	}
+	if !filter.To.IsZero() {
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+	if v := c.Query("before_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before_id"})
+			return
+		}
+		filter.BeforeID = id
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		filter.Limit = n
+	}
+
+	entries, next, err := h.auditService.List(filter)
+	if err != nil {
+		logrus.Errorf("Failed to query audit log: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query audit log"})
+		return
+	}
+	c.JSON(http.StatusOK, models.AuditLogResponse{Entries: entries, NextBeforeID: next})
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"stocky/models"
+	"stocky/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type CampaignHandler struct {
+	campaignService *services.CampaignService
+}
+
+func NewCampaignHandler(campaignService *services.CampaignService) *CampaignHandler {
+	return &CampaignHandler{campaignService: campaignService}
+}
+
+// CreateCampaign creates a reward campaign with its budget and caps
+func (h *CampaignHandler) CreateCampaign(c *gin.Context) {
+	var req models.CampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	campaign := models.Campaign{
+		Name:               req.Name,
+		StartsAt:           req.StartsAt,
+		EndsAt:             req.EndsAt,
+		BudgetINR:          req.BudgetINR,
+		PerUserQuantityCap: req.PerUserQuantityCap,
+		PerUserINRCap:      req.PerUserINRCap,
+		AllowedSymbols:     req.AllowedSymbols,
+	}
+	if err := h.campaignService.Create(c.Request.Context(), &campaign); err != nil {
+		if errors.Is(err, services.ErrInvalidCampaign) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logrus.Errorf("Failed to create campaign: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create campaign"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, campaign)
+}
+
+// ListCampaigns returns all campaigns
+func (h *CampaignHandler) ListCampaigns(c *gin.Context) {
+	campaigns, err := h.campaignService.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch campaigns"})
+		return
+	}
+
+	c.JSON(http.StatusOK, campaigns)
+}
+
+// GetCampaignReport returns a campaign's spend, including fees, from the ledger
+func (h *CampaignHandler) GetCampaignReport(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid campaign id"})
+		return
+	}
+
+	report, err := h.campaignService.Report(id)
+	if errors.Is(err, services.ErrCampaignNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to build report for campaign %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build campaign report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"stocky/models"
+	"stocky/services"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type CorporateActionHandler struct {
+	service *services.CorporateActionService
+}
+
+func NewCorporateActionHandler(service *services.CorporateActionService) *CorporateActionHandler {
+	return &CorporateActionHandler{service: service}
+}
+
+// CreateCorporateAction records a stock split or bonus issue
+func (h *CorporateActionHandler) CreateCorporateAction(c *gin.Context) {
+	var req models.CorporateActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exDate, err := time.ParseInLocation("2006-01-02", req.ExDate, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ex_date, expected YYYY-MM-DD"})
+		return
+	}
+
+	action := models.CorporateAction{
+		StockSymbol: strings.ToUpper(req.StockSymbol),
+		ActionType:  req.ActionType,
+		RatioOld:    req.RatioOld,
+		RatioNew:    req.RatioNew,
+		ExDate:      exDate,
+	}
+
+	adjusted, err := h.service.Record(c.Request.Context(), &action)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCorporateAction) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logrus.Errorf("Failed to record corporate action: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record corporate action"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CorporateActionResponse{
+		Action:          action,
+		AdjustedRewards: adjusted,
+	})
+}
+
+// ListCorporateActions returns recorded corporate actions, optionally filtered by ?symbol=
+func (h *CorporateActionHandler) ListCorporateActions(c *gin.Context) {
+	actions, err := h.service.List(strings.ToUpper(c.Query("symbol")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch corporate actions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, actions)
+}
+
+// DeclareDividend declares a per-share cash dividend for holders on the record date
+func (h *CorporateActionHandler) DeclareDividend(c *gin.Context) {
+	var req models.DividendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.AmountPerShare.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount_per_share must be greater than 0"})
+		return
+	}
+
+	recordDate, err := time.ParseInLocation("2006-01-02", req.RecordDate, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record_date, expected YYYY-MM-DD"})
+		return
+	}
+
+	dividend := models.Dividend{
+		StockSymbol:    strings.ToUpper(req.StockSymbol),
+		AmountPerShare: req.AmountPerShare,
+		RecordDate:     recordDate,
+	}
+
+	payouts, err := h.service.DeclareDividend(c.Request.Context(), &dividend)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCorporateAction) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logrus.Errorf("Failed to declare dividend: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to declare dividend"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.DividendResponse{
+		Dividend: dividend,
+		Payouts:  payouts,
+	})
+}
+
+// ListDividends returns declared dividends, optionally filtered by ?symbol=
+func (h *CorporateActionHandler) ListDividends(c *gin.Context) {
+	dividends, err := h.service.ListDividends(strings.ToUpper(c.Query("symbol")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dividends"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dividends)
+}
+
+// GetDividendHistory returns the dividends paid to a user
+func (h *CorporateActionHandler) GetDividendHistory(c *gin.Context) {
+	userID := c.Param("userId")
+
+	payouts, err := h.service.GetUserDividends(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dividends"})
+		return
+	}
+
+	response := models.DividendHistoryResponse{
+		UserID:  userID,
+		Payouts: payouts,
+	}
+	for _, payout := range payouts {
+		response.TotalGross = response.TotalGross.Add(payout.GrossAmount)
+		response.TotalTDS = response.TotalTDS.Add(payout.TDSAmount)
+		response.TotalNet = response.TotalNet.Add(payout.NetAmount)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"stocky/models"
+	"stocky/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type FeeHandler struct {
+	feeService *services.FeeService
+}
+
+func NewFeeHandler(feeService *services.FeeService) *FeeHandler {
+	return &FeeHandler{feeService: feeService}
+}
+
+// CreateFeeSchedule publishes a new fee schedule version
+func (h *FeeHandler) CreateFeeSchedule(c *gin.Context) {
+	var req models.FeeScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule := models.FeeSchedule{
+		Name:          req.Name,
+		EffectiveFrom: req.EffectiveFrom,
+	}
+	for _, component := range req.Components {
+		schedule.Components = append(schedule.Components, models.FeeComponent{
+			Code:      component.Code,
+			Name:      component.Name,
+			Rate:      component.Rate,
+			Basis:     component.Basis,
+			MinAmount: component.MinAmount,
+			MaxAmount: component.MaxAmount,
+		})
+	}
+
+	if err := h.feeService.CreateSchedule(c.Request.Context(), &schedule); err != nil {
+		if errors.Is(err, services.ErrInvalidFeeSchedule) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logrus.Errorf("Failed to create fee schedule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create fee schedule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// ListFeeSchedules returns all fee schedule versions
+func (h *FeeHandler) ListFeeSchedules(c *gin.Context) {
+	schedules, err := h.feeService.ListSchedules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch fee schedules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+// GetEffectiveFeeSchedule returns the schedule in effect now, or at ?at= (RFC3339)
+func (h *FeeHandler) GetEffectiveFeeSchedule(c *gin.Context) {
+	at := time.Now()
+	if v := c.Query("at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid at, expected RFC3339 timestamp"})
+			return
+		}
+		at = t
+	}
+
+	schedule, err := h.feeService.GetSchedule(at)
+	if err != nil {
+		if errors.Is(err, services.ErrNoFeeSchedule) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch fee schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"stocky/models"
+	"stocky/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds the dependency checks behind /readyz so a hung
+// database fails the probe instead of blocking it
+const readinessTimeout = 2 * time.Second
+
+type HealthHandler struct {
+	healthService *services.HealthService
+	startedAt     time.Time
+}
+
+func NewHealthHandler(healthService *services.HealthService) *HealthHandler {
+	return &HealthHandler{healthService: healthService, startedAt: time.Now()}
+}
+
+// Liveness reports that the process is up and serving HTTP. It checks no
+// dependencies, so a database outage doesn't get the process restarted.
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":         models.HealthStatusOK,
+		"uptime_seconds": int64(time.Since(h.startedAt).Seconds()),
+	})
+}
+
+// Readiness reports each dependency's status and returns 503 if any failed
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	result := h.healthService.Readiness(ctx)
+	status := http.StatusOK
+	if result.Status != models.HealthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, result)
+}
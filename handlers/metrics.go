@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"stocky/metrics"
+	"stocky/models"
+	"stocky/services"
+)
+
+// rejectReason labels why a reward was not booked
+func rejectReason(err error) string {
+	switch {
+	case errors.Is(err, services.ErrInvalidReward):
+		return "invalid_request"
+	case errors.Is(err, services.ErrIdempotencyKeyReused):
+		return "idempotency_key_reused"
+	case errors.Is(err, services.ErrCampaignRejected):
+		return "campaign_rejected"
+	case errors.Is(err, services.ErrUnknownSymbol):
+		return "unknown_symbol"
+	case errors.Is(err, services.ErrSymbolNotTradable):
+		return "symbol_not_tradable"
+	case errors.Is(err, services.ErrPriceUnavailable):
+		return "price_unavailable"
+	case errors.Is(err, services.ErrPriceStale):
+		return "price_stale"
+	default:
+		return "internal_error"
+	}
+}
+
+// observeRewardBooked counts a newly booked reward's stock value and fees
+func observeRewardBooked(source string, reward *models.RewardResponse) {
+	metrics.RewardsCreated.WithLabelValues(source).Inc()
+	metrics.RewardValueINR.WithLabelValues(reward.StockSymbol).Add(reward.CurrentValue.InexactFloat64())
+	if reward.Fees != nil {
+		for _, fee := range reward.Fees.Items {
+			metrics.RewardFeesINR.WithLabelValues(reward.StockSymbol, fee.Code).Add(fee.Amount.InexactFloat64())
+		}
+	}
+}
+
+// observeBatch counts the rows of a processed batch. Rows rolled back with
+// a failed all-or-nothing batch count as rejected.
+func observeBatch(response *models.BatchRewardResponse) {
+	for _, result := range response.Results {
+		switch result.Status {
+		case models.BatchRowCreated:
+			observeRewardBooked(metrics.SourceBatch, result.Reward)
+		case models.BatchRowReplayed:
+			metrics.RewardsReplayed.WithLabelValues(metrics.SourceBatch).Inc()
+		case models.BatchRowFailed:
+			metrics.RewardsRejected.WithLabelValues(metrics.SourceBatch, rejectReason(result.Err)).Inc()
+		case models.BatchRowRolledBack:
+			metrics.RewardsRejected.WithLabelValues(metrics.SourceBatch, "batch_rolled_back").Inc()
+		}
+	}
+}
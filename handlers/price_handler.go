@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"stocky/models"
+	"stocky/services"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type PriceHandler struct {
+	priceService *services.StockPriceService
+}
+
+func NewPriceHandler(priceService *services.StockPriceService) *PriceHandler {
+	return &PriceHandler{priceService: priceService}
+}
+
+// GetPriceHistory returns recorded prices for a symbol between the optional
+// from/to dates (YYYY-MM-DD, default last 30 days)
+func (h *PriceHandler) GetPriceHistory(c *gin.Context) {
+	symbol := strings.ToUpper(c.Param("symbol"))
+
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Add(24 * time.Hour)
+	from := to.AddDate(0, 0, -30)
+
+	if v := c.Query("from"); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, now.Location())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+		from = d
+	}
+	if v := c.Query("to"); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, now.Location())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+		to = d.Add(24 * time.Hour)
+	}
+
+	prices, err := h.priceService.GetPriceHistory(symbol, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PriceHistoryResponse{
+		StockSymbol: symbol,
+		Prices:      prices,
+	})
+}
+
+// OverridePrice records a price for a symbol by hand
+func (h *PriceHandler) OverridePrice(c *gin.Context) {
+	var req models.PriceOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := h.priceService.OverridePrice(c.Request.Context(), strings.ToUpper(c.Param("symbol")), req.Price, req.ObservedAt)
+	switch {
+	case errors.Is(err, services.ErrInvalidPriceOverride):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrUnknownSymbol):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		logrus.Errorf("Failed to override price: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to override price"})
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+// ImportBhavcopy loads an end-of-day bhavcopy CSV, sent as a text/csv body
+// or a multipart upload in the "file" field
+func (h *PriceHandler) ImportBhavcopy(c *gin.Context) {
+	var body io.Reader = c.Request.Body
+	if c.ContentType() == "multipart/form-data" {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "CSV upload must be sent in the file field"})
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	result, err := h.priceService.ImportBhavcopy(c.Request.Context(), body)
+	if errors.Is(err, services.ErrInvalidBhavcopy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to import bhavcopy: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import bhavcopy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
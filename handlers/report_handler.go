@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"net/http"
+	"stocky/models"
+	"stocky/services"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type ReportHandler struct {
+	ledgerService *services.LedgerService
+}
+
+func NewReportHandler(ledgerService *services.LedgerService) *ReportHandler {
+	return &ReportHandler{ledgerService: ledgerService}
+}
+
+// dateQuery parses a YYYY-MM-DD query parameter, falling back to def when absent
+func dateQuery(c *gin.Context, key string, def time.Time) (time.Time, bool) {
+	v := c.Query(key)
+	if v == "" {
+		return def, true
+	}
+	d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + key + ", expected YYYY-MM-DD"})
+		return time.Time{}, false
+	}
+	return d, true
+}
+
+func today() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// GetTrialBalance returns debit/credit totals per account as of ?as_of= (default today)
+func (h *ReportHandler) GetTrialBalance(c *gin.Context) {
+	asOf, ok := dateQuery(c, "as_of", today())
+	if !ok {
+		return
+	}
+
+	lines, err := h.ledgerService.TrialBalance(asOf.AddDate(0, 0, 1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute trial balance"})
+		return
+	}
+
+	response := models.TrialBalanceResponse{
+		AsOf:        asOf.Format("2006-01-02"),
+		Lines:       lines,
+		TotalDebit:  decimal.Zero,
+		TotalCredit: decimal.Zero,
+	}
+	for _, line := range lines {
+		response.TotalDebit = response.TotalDebit.Add(line.Debit)
+		response.TotalCredit = response.TotalCredit.Add(line.Credit)
+	}
+	response.Balanced = response.TotalDebit.Equal(response.TotalCredit)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ListAccounts returns the chart of accounts
+func (h *ReportHandler) ListAccounts(c *gin.Context) {
+	accounts, err := h.ledgerService.ListAccounts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch accounts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, accounts)
+}
+
+// GetAccountBalance returns an account's balance as of ?as_of= (default today)
+func (h *ReportHandler) GetAccountBalance(c *gin.Context) {
+	account, ok := h.account(c)
+	if !ok {
+		return
+	}
+	asOf, ok := dateQuery(c, "as_of", today())
+	if !ok {
+		return
+	}
+
+	balance, err := h.ledgerService.AccountBalance(*account, asOf.AddDate(0, 0, 1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute account balance"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AccountBalanceResponse{
+		Account: *account,
+		AsOf:    asOf.Format("2006-01-02"),
+		Balance: balance,
+	})
+}
+
+// GetGeneralLedger lists an account's postings between ?from= and ?to=
+// (inclusive, default the last 30 days) with a running balance
+func (h *ReportHandler) GetGeneralLedger(c *gin.Context) {
+	account, ok := h.account(c)
+	if !ok {
+		return
+	}
+	to, ok := dateQuery(c, "to", today())
+	if !ok {
+		return
+	}
+	from, ok := dateQuery(c, "from", to.AddDate(0, 0, -30))
+	if !ok {
+		return
+	}
+
+	opening, lines, err := h.ledgerService.GeneralLedger(*account, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch general ledger"})
+		return
+	}
+
+	closing := opening
+	if len(lines) > 0 {
+		closing = lines[len(lines)-1].RunningBalance
+	}
+
+	c.JSON(http.StatusOK, models.GeneralLedgerResponse{
+		Account:        *account,
+		From:           from.Format("2006-01-02"),
+		To:             to.Format("2006-01-02"),
+		OpeningBalance: opening,
+		Lines:          lines,
+		ClosingBalance: closing,
+	})
+}
+
+// VerifyLedger walks the ledger hash chain and reports the first entry that
+// was edited, removed or inserted outside it
+func (h *ReportHandler) VerifyLedger(c *gin.Context) {
+	result, err := h.ledgerService.VerifyChain(c.Request.Context())
+	if err != nil {
+		logrus.Errorf("Failed to verify ledger hash chain: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify ledger"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListLedgerCheckpoints returns the ledger hash chain checkpoints, newest first
+func (h *ReportHandler) ListLedgerCheckpoints(c *gin.Context) {
+	checkpoints, err := h.ledgerService.ListCheckpoints()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ledger checkpoints"})
+		return
+	}
+
+	c.JSON(http.StatusOK, checkpoints)
+}
+
+func (h *ReportHandler) account(c *gin.Context) (*models.Account, bool) {
+	account, err := h.ledgerService.GetAccount(strings.ToUpper(c.Param("code")))
+	if err == gorm.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch account"})
+		return nil, false
+	}
+	return account, true
+}
@@ -1,150 +1,186 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
+	"sort"
+	"stocky/metrics"
 	"stocky/models"
 	"stocky/services"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
+// activeRewards excludes rewards that have been reversed
+func activeRewards(db *gorm.DB) *gorm.DB {
+	return db.Where("reversed_at IS NULL")
+}
+
 type RewardHandler struct {
-	db           *gorm.DB
-	priceService *services.StockPriceService
+	db                     *gorm.DB
+	priceService           *services.StockPriceService
+	corporateActionService *services.CorporateActionService
+	rewardService          *services.RewardService
 }
 
-func NewRewardHandler(db *gorm.DB) *RewardHandler {
+func NewRewardHandler(db *gorm.DB, priceService *services.StockPriceService, corporateActionService *services.CorporateActionService, rewardService *services.RewardService) *RewardHandler {
 	return &RewardHandler{
-		db:           db,
-		priceService: services.NewStockPriceService(db),
+		db:                     db,
+		priceService:           priceService,
+		corporateActionService: corporateActionService,
+		rewardService:          rewardService,
 	}
 }
 
-// CreateReward creates a new stock reward
+// CreateReward creates a new stock reward. Retrying with the same
+// idempotency key and payload returns the original 201 response.
 func (h *RewardHandler) CreateReward(c *gin.Context) {
+	start := time.Now()
+	outcome := "rejected"
+	defer func() {
+		metrics.CreateRewardDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	}()
+
 	var req models.RewardRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		metrics.RewardsRejected.WithLabelValues(metrics.SourceSingle, "invalid_request").Inc()
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Set default timestamp if not provided
-	if req.RewardedAt.IsZero() {
-		req.RewardedAt = time.Now()
+	response, replayed, err := h.rewardService.Create(c.Request.Context(), req)
+	if err != nil {
+		metrics.RewardsRejected.WithLabelValues(metrics.SourceSingle, rejectReason(err)).Inc()
 	}
-
-	// Generate idempotency key if not provided
-	if req.IdempotencyKey == "" {
-		req.IdempotencyKey = fmt.Sprintf("%s-%s-%d", req.UserID, req.StockSymbol, req.RewardedAt.Unix())
+	switch {
+	case errors.Is(err, services.ErrInvalidReward):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrIdempotencyKeyReused), errors.Is(err, services.ErrCampaignRejected),
+		errors.Is(err, services.ErrUnknownSymbol), errors.Is(err, services.ErrSymbolNotTradable):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrPriceUnavailable), errors.Is(err, services.ErrPriceStale):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		logrus.Errorf("Failed to create reward: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reward"})
+		return
 	}
 
-	// Check for duplicate idempotency key
-	var existingReward models.StockReward
-	if err := h.db.Where("idempotency_key = ?", req.IdempotencyKey).First(&existingReward).Error; err == nil {
-		// Duplicate request - return existing reward
-		c.JSON(http.StatusConflict, gin.H{
-			"error":           "Duplicate reward request",
-			"existing_reward": existingReward,
-		})
-		return
+	if replayed {
+		outcome = "replayed"
+		metrics.RewardsReplayed.WithLabelValues(metrics.SourceSingle).Inc()
+		c.Header("Idempotent-Replayed", "true")
+	} else {
+		outcome = "created"
+		observeRewardBooked(metrics.SourceSingle, response)
 	}
+	c.JSON(http.StatusCreated, response)
+}
 
-	// Get current stock price
-	price, err := h.priceService.GetCurrentPrice(req.StockSymbol)
+// ReverseReward reverses a reward, writing ledger entries that cancel the original ones
+func (h *RewardHandler) ReverseReward(c *gin.Context) {
+	rewardID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		logrus.Errorf("Failed to get stock price: %v", err)
-		price = 0
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reward id"})
+		return
 	}
 
-	// Calculate fees (hypothetical: 0.5% brokerage + 0.1% STT + 18% GST on brokerage)
-	stockValue := req.Quantity * price
-	brokerage := stockValue * 0.005
-	stt := stockValue * 0.001
-	gst := brokerage * 0.18
-	totalFees := brokerage + stt + gst
-
-	// Begin transaction
-	tx := h.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// Create stock reward
-	reward := models.StockReward{
-		UserID:         req.UserID,
-		StockSymbol:    req.StockSymbol,
-		Quantity:       req.Quantity,
-		RewardedAt:     req.RewardedAt,
-		IdempotencyKey: req.IdempotencyKey,
+	var req models.ReverseRewardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if err := tx.Create(&reward).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reward"})
+	reward, err := h.rewardService.Reverse(c.Request.Context(), rewardID, req.Reason)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reward not found"})
+		return
+	case errors.Is(err, services.ErrRewardAlreadyReversed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Reward already reversed", "reward": reward})
+		return
+	case err != nil:
+		logrus.Errorf("Failed to reverse reward %d: %v", rewardID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reverse reward"})
 		return
 	}
 
-	// Create ledger entries (double-entry bookkeeping)
-	ledgerEntries := []models.LedgerEntry{
-		{
-			RewardID:    reward.ID,
-			EntryType:   "STOCK_CREDIT",
-			StockSymbol: req.StockSymbol,
-			Quantity:    req.Quantity,
-			Amount:      stockValue,
-			Description: fmt.Sprintf("Stock reward credited to user %s", req.UserID),
-		},
-		{
-			RewardID:    reward.ID,
-			EntryType:   "CASH_DEBIT",
-			StockSymbol: req.StockSymbol,
-			Quantity:    0,
-			Amount:      -stockValue,
-			Description: fmt.Sprintf("Company cash outflow for stock purchase"),
-		},
-		{
-			RewardID:    reward.ID,
-			EntryType:   "FEE_DEBIT",
-			StockSymbol: req.StockSymbol,
-			Quantity:    0,
-			Amount:      -totalFees,
-			Description: fmt.Sprintf("Brokerage: %.2f, STT: %.2f, GST: %.2f", brokerage, stt, gst),
-		},
-	}
-
-	for _, entry := range ledgerEntries {
-		if err := tx.Create(&entry).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create ledger entry"})
+	c.JSON(http.StatusOK, reward)
+}
+
+// CreateRewardBatch creates many rewards in one request. The body is either
+// a JSON array of reward requests or a CSV file (text/csv body, or a
+// multipart upload in the "file" field). ?mode= selects all_or_nothing
+// (default) or best_effort.
+func (h *RewardHandler) CreateRewardBatch(c *gin.Context) {
+	mode := c.DefaultQuery("mode", models.BatchModeAllOrNothing)
+
+	var rows []services.BatchRewardRow
+	switch c.ContentType() {
+	case "text/csv":
+		parsed, err := services.ParseRewardCSV(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		rows = parsed
+	case "multipart/form-data":
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "CSV upload must be sent in the file field"})
 			return
 		}
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+			return
+		}
+		defer f.Close()
+		parsed, err := services.ParseRewardCSV(f)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		rows = parsed
+	default:
+		// Decoded without binding so one bad row doesn't reject the batch
+		parsed, err := services.ParseRewardJSON(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		rows = parsed
 	}
 
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+	response, err := h.rewardService.CreateBatch(c.Request.Context(), rows, mode)
+	if errors.Is(err, services.ErrInvalidBatch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to create reward batch: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reward batch"})
 		return
 	}
 
-	logrus.Infof("Created reward for user %s: %f shares of %s", req.UserID, req.Quantity, req.StockSymbol)
+	observeBatch(response)
 
-	response := models.RewardResponse{
-		ID:           reward.ID,
-		UserID:       reward.UserID,
-		StockSymbol:  reward.StockSymbol,
-		Quantity:     reward.Quantity,
-		RewardedAt:   reward.RewardedAt,
-		CurrentPrice: price,
-		CurrentValue: req.Quantity * price,
+	status := http.StatusCreated
+	switch {
+	case !response.Committed:
+		status = http.StatusUnprocessableEntity
+	case response.Failed > 0:
+		status = http.StatusMultiStatus
 	}
-
-	c.JSON(http.StatusCreated, response)
+	c.JSON(status, response)
 }
 
 // GetTodayStocks returns all stock rewards for the user for today
@@ -152,12 +188,11 @@ func (h *RewardHandler) GetTodayStocks(c *gin.Context) {
 	userID := c.Param("userId")
 
 	// Get today's date range
-	now := time.Now()
-	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	endOfDay := startOfDay.Add(24 * time.Hour)
+	startOfDay := services.ISTDayStart(time.Now().In(services.IST))
+	endOfDay := startOfDay.AddDate(0, 0, 1)
 
 	var rewards []models.StockReward
-	if err := h.db.Where("user_id = ? AND rewarded_at >= ? AND rewarded_at < ?",
+	if err := h.db.Scopes(activeRewards).Where("user_id = ? AND rewarded_at >= ? AND rewarded_at < ?",
 		userID, startOfDay, endOfDay).Find(&rewards).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rewards"})
 		return
@@ -172,36 +207,71 @@ func (h *RewardHandler) GetTodayStocks(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// GetHistoricalINR returns the INR value of user's stock rewards for all past days
+// GetHistoricalINR returns the INR value of the user's holdings for every
+// day from the first reward up to yesterday, valued at each day's close
 func (h *RewardHandler) GetHistoricalINR(c *gin.Context) {
 	userID := c.Param("userId")
 
-	// Get rewards up to yesterday
-	now := time.Now()
-	startOfToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	// Get rewards up to yesterday. Days are Indian trading days, so that each
+	// day's end lines up with ex-dates and closes
+	startOfToday := services.ISTDayStart(time.Now().In(services.IST))
 
 	var rewards []models.StockReward
-	if err := h.db.Where("user_id = ? AND rewarded_at < ?", userID, startOfToday).
+	if err := h.db.Scopes(activeRewards).Where("user_id = ? AND rewarded_at < ?", userID, startOfToday).
 		Order("rewarded_at").Find(&rewards).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rewards"})
 		return
 	}
 
-	// Group by date and calculate INR value
-	dailyValues := make(map[string]float64)
+	daily := []models.DailyINRValue{}
+	if len(rewards) == 0 {
+		c.JSON(http.StatusOK, models.HistoricalINRResponse{UserID: userID, Daily: daily})
+		return
+	}
+
+	startDay := services.ISTDayStart(rewards[0].RewardedAt.In(services.IST))
+	days := 0
+	for d := startDay; d.Before(startOfToday); d = d.AddDate(0, 0, 1) {
+		days++
+	}
+
+	// Closing prices per symbol for every day in range
+	closes := make(map[string][]decimal.Decimal)
 	for _, reward := range rewards {
-		date := reward.RewardedAt.Format("2006-01-02")
-		price, _ := h.priceService.GetCurrentPrice(reward.StockSymbol)
-		value := reward.Quantity * price
-		dailyValues[date] += value
+		if _, ok := closes[reward.StockSymbol]; ok {
+			continue
+		}
+		prices, err := h.priceService.GetDailyClosingPrices(reward.StockSymbol, startDay, days)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price history"})
+			return
+		}
+		closes[reward.StockSymbol] = prices
+	}
+
+	adjustments, err := h.corporateActionService.GetAdjustments(services.RewardIDs(rewards))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch corporate actions"})
+		return
 	}
 
-	// Convert map to slice
-	var daily []models.DailyINRValue
-	for date, value := range dailyValues {
+	// Value holdings (adjusted for splits/bonuses) as of each day's end
+	for d := 0; d < days; d++ {
+		day := startDay.AddDate(0, 0, d)
+		endOfDay := day.AddDate(0, 0, 1)
+
+		total := decimal.Zero
+		for _, reward := range rewards {
+			if !reward.RewardedAt.Before(endOfDay) {
+				break
+			}
+			qty := services.AdjustedQuantity(reward, adjustments[reward.ID], endOfDay)
+			total = total.Add(qty.Mul(closes[reward.StockSymbol][d]))
+		}
+
 		daily = append(daily, models.DailyINRValue{
-			Date:       date,
-			TotalValue: value,
+			Date:       day.Format("2006-01-02"),
+			TotalValue: models.RoundAmount(total),
 		})
 	}
 
@@ -218,21 +288,20 @@ func (h *RewardHandler) GetStats(c *gin.Context) {
 	userID := c.Param("userId")
 
 	// Get today's rewards
-	now := time.Now()
-	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	endOfDay := startOfDay.Add(24 * time.Hour)
+	startOfDay := services.ISTDayStart(time.Now().In(services.IST))
+	endOfDay := startOfDay.AddDate(0, 0, 1)
 
 	var todayRewards []models.StockReward
-	if err := h.db.Where("user_id = ? AND rewarded_at >= ? AND rewarded_at < ?",
+	if err := h.db.Scopes(activeRewards).Where("user_id = ? AND rewarded_at >= ? AND rewarded_at < ?",
 		userID, startOfDay, endOfDay).Find(&todayRewards).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch today's rewards"})
 		return
 	}
 
 	// Group today's rewards by stock symbol
-	todayBySymbol := make(map[string]float64)
+	todayBySymbol := make(map[string]decimal.Decimal)
 	for _, reward := range todayRewards {
-		todayBySymbol[reward.StockSymbol] += reward.Quantity
+		todayBySymbol[reward.StockSymbol] = todayBySymbol[reward.StockSymbol].Add(reward.Quantity)
 	}
 
 	var todayRewardsList []models.StockQuantity
@@ -245,23 +314,45 @@ func (h *RewardHandler) GetStats(c *gin.Context) {
 
 	// Calculate total portfolio value
 	var allRewards []models.StockReward
-	if err := h.db.Where("user_id = ?", userID).Find(&allRewards).Error; err != nil {
+	if err := h.db.Scopes(activeRewards).Where("user_id = ?", userID).Find(&allRewards).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch all rewards"})
 		return
 	}
 
-	totalValue := 0.0
-	for _, reward := range allRewards {
-		price, _ := h.priceService.GetCurrentPrice(reward.StockSymbol)
-		totalValue += reward.Quantity * price
+	holdingsBySymbol, err := h.currentHoldings(allRewards)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch corporate actions"})
+		return
 	}
 
 	response := models.StatsResponse{
-		UserID:                userID,
-		TodayRewards:          todayRewardsList,
-		CurrentPortfolioValue: totalValue,
+		UserID:       userID,
+		TodayRewards: todayRewardsList,
 	}
 
+	totalValue := decimal.Zero
+	for symbol, qty := range holdingsBySymbol {
+		quote, err := h.priceService.GetQuote(symbol)
+		if err != nil {
+			logrus.Errorf("Failed to get price for %s: %v", symbol, err)
+			response.StaleSymbols = append(response.StaleSymbols, symbol)
+			response.PriceStale = true
+			continue
+		}
+		totalValue = totalValue.Add(models.RoundAmount(qty.Mul(quote.Price)))
+
+		if quote.Stale {
+			response.StaleSymbols = append(response.StaleSymbols, symbol)
+			response.PriceStale = true
+		}
+		if response.PricesAsOf == nil || quote.AsOf.Before(*response.PricesAsOf) {
+			asOf := quote.AsOf
+			response.PricesAsOf = &asOf
+		}
+	}
+	response.CurrentPortfolioValue = totalValue
+	sort.Strings(response.StaleSymbols)
+
 	c.JSON(http.StatusOK, response)
 }
 
@@ -270,39 +361,64 @@ func (h *RewardHandler) GetPortfolio(c *gin.Context) {
 	userID := c.Param("userId")
 
 	var rewards []models.StockReward
-	if err := h.db.Where("user_id = ?", userID).Find(&rewards).Error; err != nil {
+	if err := h.db.Scopes(activeRewards).Where("user_id = ?", userID).Find(&rewards).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rewards"})
 		return
 	}
 
-	// Group by stock symbol
-	holdingsBySymbol := make(map[string]float64)
-	for _, reward := range rewards {
-		holdingsBySymbol[reward.StockSymbol] += reward.Quantity
+	// Group by stock symbol, adjusted for splits and bonuses
+	holdingsBySymbol, err := h.currentHoldings(rewards)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch corporate actions"})
+		return
 	}
 
 	// Calculate values
 	var holdings []models.HoldingDetail
-	totalValue := 0.0
+	totalValue := decimal.Zero
+	anyStale := false
 
 	for symbol, qty := range holdingsBySymbol {
-		price, _ := h.priceService.GetCurrentPrice(symbol)
-		value := qty * price
-		totalValue += value
-
-		holdings = append(holdings, models.HoldingDetail{
-			StockSymbol:  symbol,
-			TotalShares:  qty,
-			CurrentPrice: price,
-			CurrentValue: value,
-		})
+		holding := models.HoldingDetail{
+			StockSymbol: symbol,
+			TotalShares: qty,
+			PriceStale:  true, // until a price is found
+		}
+		quote, err := h.priceService.GetQuote(symbol)
+		if err != nil {
+			logrus.Errorf("Failed to get price for %s: %v", symbol, err)
+		} else {
+			holding.CurrentPrice = quote.Price
+			holding.CurrentValue = models.RoundAmount(qty.Mul(quote.Price))
+			holding.PriceAsOf = &quote.AsOf
+			holding.PriceStale = quote.Stale
+		}
+		totalValue = totalValue.Add(holding.CurrentValue)
+		anyStale = anyStale || holding.PriceStale
+		holdings = append(holdings, holding)
 	}
 
 	response := models.PortfolioResponse{
 		UserID:     userID,
 		Holdings:   holdings,
 		TotalValue: totalValue,
+		PriceStale: anyStale,
 	}
 
 	c.JSON(http.StatusOK, response)
 }
+
+// currentHoldings sums reward quantities per symbol, including corporate-action adjustments
+func (h *RewardHandler) currentHoldings(rewards []models.StockReward) (map[string]decimal.Decimal, error) {
+	adjustments, err := h.corporateActionService.GetAdjustments(services.RewardIDs(rewards))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	holdings := make(map[string]decimal.Decimal)
+	for _, reward := range rewards {
+		holdings[reward.StockSymbol] = holdings[reward.StockSymbol].Add(services.AdjustedQuantity(reward, adjustments[reward.ID], now))
+	}
+	return holdings, nil
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"stocky/models"
+	"stocky/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type SecurityHandler struct {
+	securityService *services.SecurityService
+}
+
+func NewSecurityHandler(securityService *services.SecurityService) *SecurityHandler {
+	return &SecurityHandler{securityService: securityService}
+}
+
+// CreateSecurity adds a security to the securities master
+func (h *SecurityHandler) CreateSecurity(c *gin.Context) {
+	var req models.SecurityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	security := securityFromRequest(req)
+	if err := h.securityService.Create(c.Request.Context(), &security); err != nil {
+		if errors.Is(err, services.ErrInvalidSecurity) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logrus.Errorf("Failed to create security: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create security"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, security)
+}
+
+// ImportSecurities upserts securities from a CSV body (text/csv) or a
+// multipart upload in the "file" field
+func (h *SecurityHandler) ImportSecurities(c *gin.Context) {
+	var body io.Reader = c.Request.Body
+	if c.ContentType() == "multipart/form-data" {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "CSV upload must be sent in the file field"})
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	result, err := h.securityService.ImportCSV(c.Request.Context(), body)
+	if errors.Is(err, services.ErrInvalidSecurity) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to import securities: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import securities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListSecurities returns the securities master, optionally filtered by ?status=
+func (h *SecurityHandler) ListSecurities(c *gin.Context) {
+	securities, err := h.securityService.List(c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch securities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, securities)
+}
+
+// GetSecurity returns one security by symbol
+func (h *SecurityHandler) GetSecurity(c *gin.Context) {
+	security, err := h.securityService.Get(c.Param("symbol"))
+	if errors.Is(err, services.ErrSecurityNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Security not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch security"})
+		return
+	}
+
+	c.JSON(http.StatusOK, security)
+}
+
+// UpdateSecurity replaces a security's details, e.g. to delist it
+func (h *SecurityHandler) UpdateSecurity(c *gin.Context) {
+	var req models.SecurityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	security, err := h.securityService.Update(c.Request.Context(), c.Param("symbol"), securityFromRequest(req))
+	switch {
+	case errors.Is(err, services.ErrSecurityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Security not found"})
+		return
+	case errors.Is(err, services.ErrInvalidSecurity):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		logrus.Errorf("Failed to update security: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update security"})
+		return
+	}
+
+	c.JSON(http.StatusOK, security)
+}
+
+// DeleteSecurity removes a security that has never been rewarded
+func (h *SecurityHandler) DeleteSecurity(c *gin.Context) {
+	err := h.securityService.Delete(c.Request.Context(), c.Param("symbol"))
+	switch {
+	case errors.Is(err, services.ErrSecurityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Security not found"})
+		return
+	case errors.Is(err, services.ErrSecurityInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		logrus.Errorf("Failed to delete security: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete security"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func securityFromRequest(req models.SecurityRequest) models.Security {
+	return models.Security{
+		Symbol:   req.Symbol,
+		ISIN:     req.ISIN,
+		Name:     req.Name,
+		Exchange: req.Exchange,
+		LotSize:  req.LotSize,
+		Status:   req.Status,
+	}
+}
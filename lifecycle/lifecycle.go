@@ -0,0 +1,137 @@
+// Package lifecycle starts the HTTP server and background workers and shuts
+// them down in order when the process is asked to stop.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Manager coordinates shutdown. When its context is cancelled (normally by
+// SIGINT/SIGTERM) or a server fails, Run:
+//
+//  1. stops the HTTP servers, letting in-flight requests finish
+//  2. cancels the workers' context and waits for them to return
+//  3. runs the closers in reverse order of registration (e.g. the database)
+//
+// Each stage is bounded by the shutdown timeout.
+type Manager struct {
+	ctx     context.Context
+	timeout time.Duration
+
+	workerCtx    context.Context
+	stopWorkers  context.CancelFunc
+	workers      sync.WaitGroup
+	servers      []*http.Server
+	closers      []closer
+	serverFailed chan error
+}
+
+type closer struct {
+	name string
+	fn   func() error
+}
+
+// New returns a manager that shuts down when ctx is done
+func New(ctx context.Context, timeout time.Duration) *Manager {
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	return &Manager{
+		ctx:          ctx,
+		timeout:      timeout,
+		workerCtx:    workerCtx,
+		stopWorkers:  stopWorkers,
+		serverFailed: make(chan error, 1),
+	}
+}
+
+// Go runs a background worker. fn must return once its context is cancelled.
+func (m *Manager) Go(name string, fn func(ctx context.Context)) {
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		fn(m.workerCtx)
+		logrus.Infof("%s stopped", name)
+	}()
+}
+
+// Serve starts server in the background. If it fails to listen, Run shuts
+// everything down and returns the error.
+func (m *Manager) Serve(server *http.Server) {
+	m.servers = append(m.servers, server)
+	go func() {
+		logrus.Infof("Starting server on %s", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			select {
+			case m.serverFailed <- err:
+			default:
+			}
+		}
+	}()
+}
+
+// OnShutdown registers fn to run after servers and workers have stopped.
+// Closers run in reverse order of registration.
+func (m *Manager) OnShutdown(name string, fn func() error) {
+	m.closers = append(m.closers, closer{name: name, fn: fn})
+}
+
+// Run blocks until shutdown is requested, then stops everything. It returns
+// the error of a server that failed, or the first shutdown error.
+func (m *Manager) Run() error {
+	var runErr error
+	select {
+	case <-m.ctx.Done():
+		logrus.Info("Shutdown requested")
+	case runErr = <-m.serverFailed:
+		logrus.Errorf("Server failed: %v", runErr)
+	}
+
+	record := func(err error) {
+		if runErr == nil {
+			runErr = err
+		}
+	}
+
+	// Stop taking requests and let the ones in flight finish
+	serverCtx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	for _, server := range m.servers {
+		if err := server.Shutdown(serverCtx); err != nil {
+			logrus.Errorf("Failed to drain server on %s: %v", server.Addr, err)
+			record(err)
+		}
+	}
+	cancel()
+
+	// Stop background workers
+	m.stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(m.timeout):
+		err := errors.New("timed out waiting for background workers")
+		logrus.Error(err)
+		record(err)
+	}
+
+	for i := len(m.closers) - 1; i >= 0; i-- {
+		c := m.closers[i]
+		if err := c.fn(); err != nil {
+			logrus.Errorf("Failed to close %s: %v", c.name, err)
+			record(err)
+			continue
+		}
+		logrus.Infof("Closed %s", c.name)
+	}
+
+	logrus.Info("Shutdown complete")
+	return runErr
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
 	"stocky/config"
 	"stocky/database"
+	"stocky/lifecycle"
+	"stocky/middleware"
 	"stocky/routes"
 	"stocky/services"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -18,31 +25,97 @@ func main() {
 	logrus.SetFormatter(&logrus.JSONFormatter{})
 	logrus.SetLevel(logrus.InfoLevel)
 
+	// SIGINT/SIGTERM starts a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	app := lifecycle.New(ctx, cfg.ShutdownTimeout)
+
 	// Connect to database
 	db, err := database.Connect(cfg)
 	if err != nil {
 		logrus.Fatalf("Failed to connect to database: %v", err)
 	}
+	app.OnShutdown("database", func() error { return database.Close(db) })
 
 	// Run migrations
 	if err := database.RunMigrations(db); err != nil {
 		logrus.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	// Chart of accounts must exist before anything posts to the ledger
+	ledgerService := services.NewLedgerService(db, cfg.LedgerAnchorFile)
+	if err := ledgerService.EnsureChartOfAccounts(); err != nil {
+		logrus.Fatalf("Failed to seed chart of accounts: %v", err)
+	}
+	if err := ledgerService.BackfillJournals(); err != nil {
+		logrus.Fatalf("Failed to backfill ledger journals: %v", err)
+	}
+	if err := ledgerService.BackfillHashChain(); err != nil {
+		logrus.Fatalf("Failed to backfill ledger hash chain: %v", err)
+	}
+
+	// Periodically checkpoint the ledger hash chain outside the database
+	if cfg.LedgerCheckpointInterval > 0 {
+		app.Go("ledger checkpointer", func(ctx context.Context) {
+			ledgerService.StartCheckpointer(ctx, cfg.LedgerCheckpointInterval)
+		})
+	} else {
+		logrus.Warn("LEDGER_CHECKPOINT_INTERVAL is not positive; ledger checkpoints are disabled")
+	}
+
+	// Only symbols in the securities master can be rewarded or priced
+	securityService := services.NewSecurityService(db)
+	if err := securityService.Seed(cfg.SecuritiesFile); err != nil {
+		logrus.Fatalf("Failed to seed securities master: %v", err)
+	}
+
 	// Initialize stock price service
-	priceService := services.NewStockPriceService(db)
-	go priceService.StartPriceUpdater() // Start hourly price updates
+	priceProvider, err := services.NewPriceProvider(cfg)
+	if err != nil {
+		logrus.Fatalf("Failed to configure price provider: %v", err)
+	}
+	logrus.Infof("Using %s price provider", priceProvider.Name())
+
+	calendar, err := services.LoadMarketCalendar(cfg)
+	if err != nil {
+		logrus.Fatalf("Failed to load market calendar: %v", err)
+	}
+
+	priceService := services.NewStockPriceService(db, priceProvider, cfg.PriceStaleAfter, calendar)
+	app.Go("price updater", priceService.StartPriceUpdater) // Start hourly price updates
+
+	// Apply stock splits, bonus issues and dividends as their dates arrive
+	corporateActionService := services.NewCorporateActionService(db, cfg, ledgerService)
+	app.Go("corporate action processor", corporateActionService.StartProcessor)
+
+	// Fee schedules are versioned in the database; seed the default on first run
+	feeService := services.NewFeeService(db)
+	if err := feeService.EnsureDefaultSchedule(); err != nil {
+		logrus.Fatalf("Failed to seed fee schedule: %v", err)
+	}
+
+	campaignService := services.NewCampaignService(db)
+	rewardService := services.NewRewardService(db, priceService, feeService, ledgerService, campaignService, securityService)
+
+	// Partners authenticate with API keys, end users with JWTs
+	authService := services.NewAuthService(db, cfg)
 
 	// Setup router
 	router := gin.Default()
+	router.Use(middleware.RequestID())
+
+	// Health probes for the orchestrator
+	routes.SetupHealthRoutes(router, services.NewHealthService(db, priceService))
+	routes.SetupMetricsRoutes(router)
 
 	// API routes
 	api := router.Group("/api/v1")
-	routes.SetupRoutes(api, db)
+	routes.SetupRoutes(api, db, priceService, corporateActionService, rewardService, campaignService, securityService, feeService, ledgerService, authService)
 
-	// Start server
-	logrus.Info("Starting server on :8080")
-	if err := router.Run(":8080"); err != nil {
-		logrus.Fatalf("Failed to start server: %v", err)
+	// Start server; on shutdown, in-flight requests finish before the
+	// workers stop and the database closes
+	app.Serve(&http.Server{Addr: ":" + cfg.ServerPort, Handler: router})
+	if err := app.Run(); err != nil {
+		logrus.Fatalf("Server stopped: %v", err)
 	}
 }
@@ -0,0 +1,86 @@
+// Package metrics defines the Prometheus metrics served at /metrics
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "stocky"
+
+// Reward sources
+const (
+	SourceSingle = "single"
+	SourceBatch  = "batch"
+)
+
+// Price update run results
+const (
+	ResultSuccess     = "success"
+	ResultFailed      = "failed"
+	ResultInterrupted = "interrupted"
+)
+
+var (
+	RewardsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rewards_created_total",
+		Help:      "Rewards booked, by source (single or batch).",
+	}, []string{"source"})
+
+	RewardsReplayed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rewards_replayed_total",
+		Help:      "Reward requests answered from an earlier request with the same idempotency key.",
+	}, []string{"source"})
+
+	RewardsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rewards_rejected_total",
+		Help:      "Reward requests that were not booked, by source and reason.",
+	}, []string{"source", "reason"})
+
+	CreateRewardDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "create_reward_duration_seconds",
+		Help:      "Time taken to handle a single reward request, by outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	RewardValueINR = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reward_value_inr_total",
+		Help:      "INR value of stock booked as rewards, by symbol.",
+	}, []string{"symbol"})
+
+	RewardFeesINR = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reward_fees_inr_total",
+		Help:      "INR fees booked on rewards, by symbol and fee component.",
+	}, []string{"symbol", "fee"})
+
+	PriceUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "price_updates_total",
+		Help:      "Prices fetched by the background updater, by symbol and result.",
+	}, []string{"symbol", "result"})
+
+	PriceUpdateRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "price_update_runs_total",
+		Help:      "Background price update runs, by result (success, failed or interrupted).",
+	}, []string{"result"})
+
+	PriceUpdateDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "price_update_duration_seconds",
+		Help:      "Time taken to update prices for all listed securities.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 10),
+	})
+
+	PriceLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "price_last_success_timestamp_seconds",
+		Help:      "Unix time of the last price update run that recorded prices.",
+	})
+)
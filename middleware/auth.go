@@ -0,0 +1,123 @@
+// Package middleware holds gin middleware shared by the API routes
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"stocky/audit"
+	"stocky/models"
+	"stocky/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// APIKeyHeader carries a partner's API key
+const APIKeyHeader = "X-API-Key"
+
+const principalKey = "principal"
+
+// RequireAPIKey admits requests carrying an active API key with scope
+func RequireAPIKey(auth *services.AuthService, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := authenticateAPIKey(c, auth)
+		if !ok {
+			return
+		}
+		if !principal.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key lacks the " + scope + " scope"})
+			return
+		}
+		setPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// RequireUserOrAPIKey admits an end user's bearer token, or an API key with
+// scope. A user may only reach routes for their own :userId.
+func RequireUserOrAPIKey(auth *services.AuthService, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader(APIKeyHeader) != "" {
+			principal, ok := authenticateAPIKey(c, auth)
+			if !ok {
+				return
+			}
+			if !principal.HasScope(scope) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key lacks the " + scope + " scope"})
+				return
+			}
+			setPrincipal(c, principal)
+			c.Next()
+			return
+		}
+
+		token, ok := bearerToken(c)
+		if !ok {
+			abortUnauthenticated(c, "Bearer token or API key required")
+			return
+		}
+		principal, err := auth.AuthenticateToken(token)
+		if err != nil {
+			abortUnauthenticated(c, err.Error())
+			return
+		}
+		if userID := c.Param("userId"); userID != "" && userID != principal.Subject {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Token does not grant access to this user"})
+			return
+		}
+		setPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// setPrincipal stores the authenticated caller for handlers and records it
+// as the actor for audited changes made by the request
+func setPrincipal(c *gin.Context, principal *models.Principal) {
+	c.Set(principalKey, principal)
+	c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), principal.Actor()))
+}
+
+// CurrentPrincipal returns the caller authenticated by the auth middleware,
+// or nil on unauthenticated routes
+func CurrentPrincipal(c *gin.Context) *models.Principal {
+	if value, ok := c.Get(principalKey); ok {
+		return value.(*models.Principal)
+	}
+	return nil
+}
+
+// authenticateAPIKey returns the principal for the request's API key, or
+// aborts the request and returns false
+func authenticateAPIKey(c *gin.Context, auth *services.AuthService) (*models.Principal, bool) {
+	key := c.GetHeader(APIKeyHeader)
+	if key == "" {
+		abortUnauthenticated(c, "API key required in the "+APIKeyHeader+" header")
+		return nil, false
+	}
+	principal, err := auth.AuthenticateAPIKey(key)
+	if errors.Is(err, services.ErrInvalidAPIKey) {
+		abortUnauthenticated(c, err.Error())
+		return nil, false
+	}
+	if err != nil {
+		logrus.Errorf("Failed to authenticate API key: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate"})
+		return nil, false
+	}
+	return principal, true
+}
+
+func bearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+func abortUnauthenticated(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer realm="stocky"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message})
+}
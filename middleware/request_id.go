@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"stocky/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID. A caller's ID is kept so requests
+// can be traced across systems; otherwise one is generated.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength matches the audit log column
+const maxRequestIDLength = 64
+
+// RequestID gives every request an ID, returned in the response header and
+// recorded in the audit log
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err != nil {
+				panic(err)
+			}
+			id = hex.EncodeToString(b)
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(audit.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
@@ -1,69 +1,698 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 // StockReward represents a reward event
 type StockReward struct {
-	ID             int64     `json:"id" gorm:"primaryKey"`
-	UserID         string    `json:"user_id" gorm:"type:varchar(100);not null;index"`
-	StockSymbol    string    `json:"stock_symbol" gorm:"type:varchar(20);not null;index"`
-	Quantity       float64   `json:"quantity" gorm:"type:numeric(18,6);not null"`
-	RewardedAt     time.Time `json:"rewarded_at" gorm:"not null;index"`
-	IdempotencyKey string    `json:"idempotency_key" gorm:"type:varchar(100);uniqueIndex"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             int64               `json:"id" gorm:"primaryKey"`
+	UserID         string              `json:"user_id" gorm:"type:varchar(100);not null;index"`
+	StockSymbol    string              `json:"stock_symbol" gorm:"type:varchar(20);not null;index"`
+	Quantity       decimal.Decimal     `json:"quantity" gorm:"type:numeric(18,6);not null"`
+	RewardedAt     time.Time           `json:"rewarded_at" gorm:"not null;index"`
+	IdempotencyKey string              `json:"idempotency_key" gorm:"type:varchar(100);uniqueIndex"`
+	CampaignID     *int64              `json:"campaign_id,omitempty" gorm:"index"`
+	AmountINR      decimal.NullDecimal `json:"amount_inr" gorm:"type:numeric(18,2)"` // set for rewards stated in rupees
+	ReversedAt     *time.Time          `json:"reversed_at,omitempty" gorm:"index"`
+	ReversalReason string              `json:"reversal_reason,omitempty" gorm:"type:text"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+}
+
+// Security statuses
+const (
+	SecurityStatusActive    = "ACTIVE"
+	SecurityStatusSuspended = "SUSPENDED"
+	SecurityStatusDelisted  = "DELISTED"
+)
+
+// Exchanges a security can be listed on
+const (
+	ExchangeNSE = "NSE"
+	ExchangeBSE = "BSE"
+)
+
+// Security is an entry in the securities master. Only ACTIVE securities can
+// be rewarded, in quantities that are a multiple of LotSize.
+type Security struct {
+	ID        int64           `json:"id" gorm:"primaryKey"`
+	Symbol    string          `json:"symbol" gorm:"type:varchar(20);not null;uniqueIndex"`
+	ISIN      string          `json:"isin" gorm:"type:varchar(12);index:idx_securities_isin,unique,where:isin <> ''"`
+	Name      string          `json:"name" gorm:"type:varchar(200);not null"`
+	Exchange  string          `json:"exchange" gorm:"type:varchar(10);not null"`
+	LotSize   decimal.Decimal `json:"lot_size" gorm:"type:numeric(18,6);not null"`
+	Status    string          `json:"status" gorm:"type:varchar(20);not null;index"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// IdempotencyRecord reserves an idempotency key for a reward request. The
+// unique key makes concurrent retries race on insert rather than on a read,
+// and the stored fingerprint and response let a retry get the original result.
+type IdempotencyRecord struct {
+	ID           int64     `json:"id" gorm:"primaryKey"`
+	Key          string    `json:"key" gorm:"type:varchar(100);not null;uniqueIndex"`
+	Fingerprint  string    `json:"fingerprint" gorm:"type:varchar(64)"`
+	RewardID     *int64    `json:"reward_id" gorm:"index"`
+	ResponseBody string    `json:"-" gorm:"type:text"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Campaign groups rewards given for one purpose. Spend (stock value plus
+// fees) is limited by BudgetINR; each user's rewards can additionally be
+// capped by share count and by stock value. An empty AllowedSymbols list
+// allows any symbol.
+type Campaign struct {
+	ID                 int64               `json:"id" gorm:"primaryKey"`
+	Name               string              `json:"name" gorm:"type:varchar(100);not null;uniqueIndex"`
+	StartsAt           time.Time           `json:"starts_at" gorm:"not null"`
+	EndsAt             *time.Time          `json:"ends_at,omitempty"`
+	BudgetINR          decimal.Decimal     `json:"budget_inr" gorm:"type:numeric(18,2);not null"`
+	PerUserQuantityCap decimal.NullDecimal `json:"per_user_quantity_cap" gorm:"type:numeric(18,6)"`
+	PerUserINRCap      decimal.NullDecimal `json:"per_user_inr_cap" gorm:"type:numeric(18,2)"`
+	AllowedSymbols     []string            `json:"allowed_symbols" gorm:"type:jsonb;serializer:json"`
+	CreatedAt          time.Time           `json:"created_at"`
+	UpdatedAt          time.Time           `json:"updated_at"`
+}
+
+// AllowsSymbol reports whether rewards in symbol may be given under the campaign
+func (c Campaign) AllowsSymbol(symbol string) bool {
+	if len(c.AllowedSymbols) == 0 {
+		return true
+	}
+	for _, allowed := range c.AllowedSymbols {
+		if allowed == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveAt reports whether t falls within the campaign's start and end
+func (c Campaign) ActiveAt(t time.Time) bool {
+	if t.Before(c.StartsAt) {
+		return false
+	}
+	return c.EndsAt == nil || t.Before(*c.EndsAt)
+}
+
+// API key scopes; each grants access to one group of partner routes
+const (
+	ScopeRewardsWrite = "rewards:write" // create, reverse and batch rewards
+	ScopeUsersRead    = "users:read"    // any user's rewards, stats, portfolio and dividends
+	ScopePricesRead   = "prices:read"   // price history
+)
+
+// Admin roles. Any role can read everything under /admin; changes need the
+// role that owns them.
+const (
+	RoleOps     = "ops"     // securities, prices, corporate actions and API keys
+	RoleFinance = "finance" // dividends, campaigns, fee schedules and reward reversals
+	RoleAuditor = "auditor" // read-only
+)
+
+// APIKey authenticates a partner system or an admin. Only a SHA-256 hash of
+// the key is stored; the key itself is returned once, when it is created.
+// Issue admins one key each so their actions can be told apart.
+type APIKey struct {
+	ID         int64      `json:"id" gorm:"primaryKey"`
+	Name       string     `json:"name" gorm:"type:varchar(100);not null"`
+	Prefix     string     `json:"prefix" gorm:"type:varchar(16);not null"` // first characters of the key, to tell keys apart
+	KeyHash    string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	Scopes     []string   `json:"scopes" gorm:"type:jsonb;serializer:json"`
+	Roles      []string   `json:"roles" gorm:"type:jsonb;serializer:json"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Principal kinds
+const (
+	PrincipalAPIKey = "api_key"
+	PrincipalUser   = "user"
+)
+
+// Principal is the authenticated caller of a request: a partner's API key,
+// or an end user identified by the subject of their token
+type Principal struct {
+	Kind     string
+	Subject  string // user ID for users, key name for API keys
+	APIKeyID int64
+	Scopes   []string
+	Roles    []string
+}
+
+// HasScope reports whether the principal's API key grants scope
+func (p Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole reports whether the principal has any of roles
+func (p Principal) HasRole(roles ...string) bool {
+	for _, granted := range p.Roles {
+		for _, role := range roles {
+			if granted == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Actor identifies the principal in logs and records, e.g. "api_key:3:alice"
+// or "user:user123"
+func (p Principal) Actor() string {
+	if p.Kind == PrincipalAPIKey {
+		return fmt.Sprintf("%s:%d:%s", p.Kind, p.APIKeyID, p.Subject)
+	}
+	return p.Kind + ":" + p.Subject
+}
+
+// Audit log actions
+const (
+	AuditRewardCreate           = "reward.create"
+	AuditRewardReverse          = "reward.reverse"
+	AuditPriceRecord            = "price.record"
+	AuditPriceOverride          = "price.override"
+	AuditBhavcopyImport         = "price.bhavcopy_import"
+	AuditSecurityCreate         = "security.create"
+	AuditSecurityUpdate         = "security.update"
+	AuditSecurityDelete         = "security.delete"
+	AuditCampaignCreate         = "campaign.create"
+	AuditFeeScheduleCreate      = "fee_schedule.create"
+	AuditCorporateActionCreate  = "corporate_action.create"
+	AuditCorporateActionProcess = "corporate_action.process"
+	AuditDividendDeclare        = "dividend.declare"
+	AuditDividendProcess        = "dividend.process"
+	AuditAPIKeyCreate           = "api_key.create"
+	AuditAPIKeyRevoke           = "api_key.revoke"
+	AuditLedgerCheckpoint       = "ledger.checkpoint"
+)
+
+// AuditLog is an append-only record of a change: who made it, in which
+// request, and the entity's state before and after. A database trigger
+// refuses updates and deletes.
+type AuditLog struct {
+	ID         int64           `json:"id" gorm:"primaryKey"`
+	Actor      string          `json:"actor" gorm:"type:varchar(150);not null;index"`
+	Action     string          `json:"action" gorm:"type:varchar(50);not null;index"`
+	EntityType string          `json:"entity_type" gorm:"type:varchar(50);not null;index:idx_audit_logs_entity,priority:1"`
+	EntityID   string          `json:"entity_id" gorm:"type:varchar(100);not null;index:idx_audit_logs_entity,priority:2"`
+	Before     json.RawMessage `json:"before" gorm:"type:jsonb"`
+	After      json.RawMessage `json:"after" gorm:"type:jsonb"`
+	RequestID  string          `json:"request_id,omitempty" gorm:"type:varchar(64);index"`
+	CreatedAt  time.Time       `json:"created_at" gorm:"not null;index"`
+}
+
+// Ledger entry types
+const (
+	EntryTypeStockCredit = "STOCK_CREDIT"
+	EntryTypeCashDebit   = "CASH_DEBIT"
+	EntryTypeFeeDebit    = "FEE_DEBIT"
+	EntryTypeFeeExpense  = "FEE_EXPENSE"
+
+	// Dividend payouts: cash received from the issuer as registered holder,
+	// the gross amount owed to the user, and tax deducted at source
+	EntryTypeDividendReceived = "DIVIDEND_RECEIVED"
+	EntryTypeDividendCredit   = "DIVIDEND_CREDIT"
+	EntryTypeTDSDebit         = "TDS_DEBIT"
+	EntryTypeTDSWithheld      = "TDS_WITHHELD"
+
+	// EntryTypeCorporateAction adjusts a reward's quantity for a split or bonus
+	EntryTypeCorporateAction = "CORPORATE_ACTION"
+
+	// ReversalSuffix is appended to the type of an entry that cancels another
+	ReversalSuffix = "_REVERSAL"
+)
+
+// Account types
+const (
+	AccountTypeAsset     = "ASSET"
+	AccountTypeLiability = "LIABILITY"
+	AccountTypeEquity    = "EQUITY"
+	AccountTypeIncome    = "INCOME"
+	AccountTypeExpense   = "EXPENSE"
+)
+
+// Chart of accounts
+const (
+	AccountCompanyCash         = "COMPANY_CASH"
+	AccountUserStockHoldings   = "USER_STOCK_HOLDINGS"
+	AccountGSTInputCredit      = "GST_INPUT_CREDIT"
+	AccountUserDividendPayable = "USER_DIVIDEND_PAYABLE"
+	AccountTDSPayable          = "TDS_PAYABLE"
+	AccountFeeExpense          = "FEE_EXPENSE"
+)
+
+// Account is an entry in the chart of accounts
+type Account struct {
+	ID        int64     `json:"id" gorm:"primaryKey"`
+	Code      string    `json:"code" gorm:"type:varchar(50);uniqueIndex;not null"`
+	Name      string    `json:"name" gorm:"type:varchar(100);not null"`
+	Type      string    `json:"type" gorm:"type:varchar(20);not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DebitNormal reports whether the account's balance grows with debits
+func (a Account) DebitNormal() bool {
+	return a.Type == AccountTypeAsset || a.Type == AccountTypeExpense
 }
 
-// LedgerEntry represents double-entry bookkeeping
+// Journal groups the ledger entries (postings) of one business event.
+// The signed amounts of a journal's entries always sum to zero.
+type Journal struct {
+	ID          int64         `json:"id" gorm:"primaryKey"`
+	Reference   string        `json:"reference" gorm:"type:varchar(100);not null;index"` // e.g. reward:42
+	Description string        `json:"description" gorm:"type:text"`
+	PostedAt    time.Time     `json:"posted_at" gorm:"not null;index"`
+	Entries     []LedgerEntry `json:"entries,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// LedgerEntry is a posting to one account within a journal. Amount is
+// signed: positive is a debit, negative is a credit.
 type LedgerEntry struct {
+	ID                int64               `json:"id" gorm:"primaryKey"`
+	JournalID         *int64              `json:"journal_id,omitempty" gorm:"index"`
+	AccountCode       string              `json:"account_code" gorm:"type:varchar(50);index"`
+	RewardID          *int64              `json:"reward_id,omitempty" gorm:"index"` // nil for entries not tied to a reward (e.g. dividends)
+	UserID            string              `json:"user_id" gorm:"type:varchar(100);index"`
+	EntryType         string              `json:"entry_type" gorm:"type:varchar(50);not null"` // STOCK_CREDIT, CASH_DEBIT, FEE_DEBIT (+ _REVERSAL)
+	StockSymbol       string              `json:"stock_symbol" gorm:"type:varchar(20)"`
+	FeeCode           string              `json:"fee_code,omitempty" gorm:"type:varchar(30)"` // fee component for FEE_DEBIT entries
+	Quantity          decimal.Decimal     `json:"quantity" gorm:"type:numeric(18,6)"`
+	Amount            decimal.Decimal     `json:"amount" gorm:"type:numeric(18,4)"`  // INR amount, debit positive
+	Price             decimal.NullDecimal `json:"price" gorm:"type:numeric(18,4)"`   // unit price the stock was valued at
+	Residue           decimal.NullDecimal `json:"residue" gorm:"type:numeric(18,4)"` // INR left over converting an INR reward to shares
+	Description       string              `json:"description" gorm:"type:text"`
+	ReversesID        *int64              `json:"reverses_id,omitempty" gorm:"index"` // entry cancelled by this one
+	CorporateActionID *int64              `json:"corporate_action_id,omitempty" gorm:"index"`
+	DividendID        *int64              `json:"dividend_id,omitempty" gorm:"index"`
+	CreatedAt         time.Time           `json:"created_at"`
+	PrevHash          string              `json:"prev_hash" gorm:"type:varchar(64);not null;default:''"` // Hash of the entry before this one
+	Hash              string              `json:"hash" gorm:"type:varchar(64);not null;default:''"`      // SHA-256 of PrevHash and this entry's content
+}
+
+// LedgerCheckpoint records the head of the ledger hash chain at a point in
+// time. Each checkpoint is also anchored outside the database, so a chain
+// rewritten from some entry onward can still be detected.
+type LedgerCheckpoint struct {
 	ID          int64     `json:"id" gorm:"primaryKey"`
-	RewardID    int64     `json:"reward_id" gorm:"not null;index"`
-	EntryType   string    `json:"entry_type" gorm:"type:varchar(50);not null"` // STOCK_CREDIT, CASH_DEBIT, FEE_DEBIT
-	StockSymbol string    `json:"stock_symbol" gorm:"type:varchar(20)"`
-	Quantity    float64   `json:"quantity" gorm:"type:numeric(18,6)"`
-	Amount      float64   `json:"amount" gorm:"type:numeric(18,4)"` // INR amount
-	Description string    `json:"description" gorm:"type:text"`
+	LastEntryID int64     `json:"last_entry_id" gorm:"not null;index"`
+	Hash        string    `json:"hash" gorm:"type:varchar(64);not null"` // hash of the entry LastEntryID
+	EntryCount  int64     `json:"entry_count"`                           // entries in the chain up to LastEntryID
 	CreatedAt   time.Time `json:"created_at"`
 }
 
-// StockPrice represents current stock prices
+// Ledger chain verification statuses
+const (
+	LedgerChainOK     = "ok"
+	LedgerChainBroken = "broken"
+)
+
+// LedgerChainBreak is the first ledger entry that fails verification
+type LedgerChainBreak struct {
+	EntryID      int64  `json:"entry_id" example:"1042"`
+	CheckpointID int64  `json:"checkpoint_id,omitempty"` // set when a checkpoint disagrees
+	Reason       string `json:"reason" example:"hash does not match the entry's content"`
+	Expected     string `json:"expected,omitempty"`
+	Found        string `json:"found,omitempty"`
+}
+
+// LedgerVerification is the result of walking the ledger hash chain
+type LedgerVerification struct {
+	Status             string            `json:"status" example:"ok"`
+	EntriesChecked     int64             `json:"entries_checked" example:"5120"`
+	LastEntryID        int64             `json:"last_entry_id,omitempty"`
+	LastHash           string            `json:"last_hash,omitempty"`
+	CheckpointsChecked int               `json:"checkpoints_checked"`
+	FirstBreak         *LedgerChainBreak `json:"first_break,omitempty"`
+	VerifiedAt         time.Time         `json:"verified_at"`
+}
+
+// FeeCodeGST is the fee component for GST, which is booked as input credit
+// rather than expense
+const FeeCodeGST = "GST"
+
+// Fee component bases
+const (
+	// FeeBasisTradeValue charges a component on the value of stock bought
+	FeeBasisTradeValue = "TRADE_VALUE"
+)
+
+// FeeSchedule is a versioned set of fee components effective from a date.
+// The schedule with the latest EffectiveFrom not after a trade applies to it.
+type FeeSchedule struct {
+	ID            int64          `json:"id" gorm:"primaryKey"`
+	Name          string         `json:"name" gorm:"type:varchar(100);not null"`
+	EffectiveFrom time.Time      `json:"effective_from" gorm:"not null;uniqueIndex"`
+	Components    []FeeComponent `json:"components" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// FeeComponent is one charge within a fee schedule. Basis is either
+// TRADE_VALUE or a comma-separated list of earlier component codes whose
+// amounts the rate applies to (e.g. GST on "BROKERAGE,EXCHANGE_TXN").
+type FeeComponent struct {
+	ID            int64               `json:"id" gorm:"primaryKey"`
+	FeeScheduleID int64               `json:"fee_schedule_id" gorm:"not null;index"`
+	Sequence      int                 `json:"sequence" gorm:"not null"`
+	Code          string              `json:"code" gorm:"type:varchar(30);not null"`
+	Name          string              `json:"name" gorm:"type:varchar(100);not null"`
+	Rate          decimal.Decimal     `json:"rate" gorm:"type:numeric(12,8);not null"`
+	Basis         string              `json:"basis" gorm:"type:varchar(200);not null"`
+	MinAmount     decimal.NullDecimal `json:"min_amount" gorm:"type:numeric(18,4)"`
+	MaxAmount     decimal.NullDecimal `json:"max_amount" gorm:"type:numeric(18,4)"`
+}
+
+// FeeItem is a computed fee component
+type FeeItem struct {
+	Code   string          `json:"code" example:"BROKERAGE"`
+	Name   string          `json:"name" example:"Brokerage"`
+	Amount decimal.Decimal `json:"amount" example:"122.54"`
+}
+
+// FeeBreakdown is the itemised fee for a trade under one schedule
+type FeeBreakdown struct {
+	ScheduleID int64           `json:"schedule_id" example:"1"`
+	Items      []FeeItem       `json:"items"`
+	Total      decimal.Decimal `json:"total" example:"175.23"`
+}
+
+// Corporate action types
+const (
+	CorporateActionSplit = "SPLIT"
+	CorporateActionBonus = "BONUS"
+)
+
+// CorporateAction records a split or bonus issue for a symbol.
+// SPLIT: every RatioOld shares become RatioNew shares (1:5 split => 1, 5).
+// BONUS: RatioNew bonus shares are issued for every RatioOld held (1:1 bonus => 1, 1).
+type CorporateAction struct {
+	ID          int64      `json:"id" gorm:"primaryKey"`
+	StockSymbol string     `json:"stock_symbol" gorm:"type:varchar(20);not null;index"`
+	ActionType  string     `json:"action_type" gorm:"type:varchar(20);not null"`
+	RatioOld    int64      `json:"ratio_old" gorm:"not null"`
+	RatioNew    int64      `json:"ratio_new" gorm:"not null"`
+	ExDate      time.Time  `json:"ex_date" gorm:"type:date;not null;index"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// QuantityFactor returns the multiplier applied to holdings on the ex-date
+func (a CorporateAction) QuantityFactor() decimal.Decimal {
+	old := decimal.NewFromInt(a.RatioOld)
+	switch a.ActionType {
+	case CorporateActionSplit:
+		return decimal.NewFromInt(a.RatioNew).Div(old)
+	case CorporateActionBonus:
+		return decimal.NewFromInt(a.RatioOld + a.RatioNew).Div(old)
+	}
+	return decimal.NewFromInt(1)
+}
+
+// StockPrice holds the latest known price per symbol (a view over PriceHistory)
 type StockPrice struct {
-	ID          int64     `json:"id" gorm:"primaryKey"`
-	StockSymbol string    `json:"stock_symbol" gorm:"type:varchar(20);uniqueIndex;not null"`
-	Price       float64   `json:"price" gorm:"type:numeric(18,4);not null"`
-	UpdatedAt   time.Time `json:"updated_at" gorm:"index"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          int64           `json:"id" gorm:"primaryKey"`
+	StockSymbol string          `json:"stock_symbol" gorm:"type:varchar(20);uniqueIndex;not null"`
+	Price       decimal.Decimal `json:"price" gorm:"type:numeric(18,4);not null"`
+	Source      string          `json:"source" gorm:"type:varchar(50)"`
+	UpdatedAt   time.Time       `json:"updated_at" gorm:"index"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// Quote is the latest known price for a symbol and when it was observed
+type Quote struct {
+	Price decimal.Decimal
+	AsOf  time.Time
+	Stale bool
+}
+
+// PriceHistory is an append-only record of every price observed for a symbol
+type PriceHistory struct {
+	ID          int64           `json:"id" gorm:"primaryKey"`
+	StockSymbol string          `json:"stock_symbol" gorm:"type:varchar(20);not null;index:idx_price_history_symbol_observed,priority:1"`
+	Price       decimal.Decimal `json:"price" gorm:"type:numeric(18,4);not null"`
+	Source      string          `json:"source" gorm:"type:varchar(50);not null"`
+	ObservedAt  time.Time       `json:"observed_at" gorm:"not null;index:idx_price_history_symbol_observed,priority:2"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// Price sources other than the configured provider
+const (
+	PriceSourceBhavcopy = "bhavcopy" // exchange end-of-day files
+	PriceSourceManual   = "manual"   // admin overrides
+)
+
+// DailyPrice is the official end-of-day OHLC for a symbol from a bhavcopy
+// file. Its close is the authoritative price for the trade date.
+type DailyPrice struct {
+	ID          int64           `json:"id" gorm:"primaryKey"`
+	StockSymbol string          `json:"stock_symbol" gorm:"type:varchar(20);not null;uniqueIndex:idx_daily_price_symbol_date,priority:1"`
+	TradeDate   time.Time       `json:"trade_date" gorm:"type:date;not null;uniqueIndex:idx_daily_price_symbol_date,priority:2;index"`
+	Series      string          `json:"series" gorm:"type:varchar(5)"`
+	Open        decimal.Decimal `json:"open" gorm:"type:numeric(18,4);not null"`
+	High        decimal.Decimal `json:"high" gorm:"type:numeric(18,4);not null"`
+	Low         decimal.Decimal `json:"low" gorm:"type:numeric(18,4);not null"`
+	Close       decimal.Decimal `json:"close" gorm:"type:numeric(18,4);not null"`
+	Volume      int64           `json:"volume"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// BhavcopyImportResponse API response for a bhavcopy import
+type BhavcopyImportResponse struct {
+	TradeDates     []string `json:"trade_dates" example:"2025-11-10"`
+	Imported       int      `json:"imported" example:"10"`
+	SkippedSeries  int      `json:"skipped_series" example:"1850"` // rows outside the EQ series
+	UnknownSymbols int      `json:"unknown_symbols" example:"320"` // rows for symbols not in the securities master
 }
 
 // UserPortfolio represents aggregated user holdings
 type UserPortfolio struct {
-	UserID       string  `json:"user_id"`
-	StockSymbol  string  `json:"stock_symbol"`
-	TotalShares  float64 `json:"total_shares"`
-	CurrentPrice float64 `json:"current_price"`
-	CurrentValue float64 `json:"current_value"`
+	UserID       string          `json:"user_id"`
+	StockSymbol  string          `json:"stock_symbol"`
+	TotalShares  decimal.Decimal `json:"total_shares"`
+	CurrentPrice decimal.Decimal `json:"current_price"`
+	CurrentValue decimal.Decimal `json:"current_value"`
+}
+
+// Dividend is a cash dividend declared per share for holders on the record date
+type Dividend struct {
+	ID             int64           `json:"id" gorm:"primaryKey"`
+	StockSymbol    string          `json:"stock_symbol" gorm:"type:varchar(20);not null;index"`
+	AmountPerShare decimal.Decimal `json:"amount_per_share" gorm:"type:numeric(18,4);not null"`
+	RecordDate     time.Time       `json:"record_date" gorm:"type:date;not null;index"`
+	ProcessedAt    *time.Time      `json:"processed_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// DividendPayout is one user's entitlement to a dividend
+type DividendPayout struct {
+	ID               int64           `json:"id" gorm:"primaryKey"`
+	DividendID       int64           `json:"dividend_id" gorm:"not null;uniqueIndex:idx_dividend_payout_user,priority:1"`
+	UserID           string          `json:"user_id" gorm:"type:varchar(100);not null;uniqueIndex:idx_dividend_payout_user,priority:2;index"`
+	StockSymbol      string          `json:"stock_symbol" gorm:"type:varchar(20);not null"`
+	RecordDate       time.Time       `json:"record_date" gorm:"type:date;not null"`
+	EligibleQuantity decimal.Decimal `json:"eligible_quantity" gorm:"type:numeric(18,6);not null"`
+	AmountPerShare   decimal.Decimal `json:"amount_per_share" gorm:"type:numeric(18,4);not null"`
+	GrossAmount      decimal.Decimal `json:"gross_amount" gorm:"type:numeric(18,4);not null"`
+	TDSAmount        decimal.Decimal `json:"tds_amount" gorm:"type:numeric(18,4);not null"`
+	NetAmount        decimal.Decimal `json:"net_amount" gorm:"type:numeric(18,4);not null"`
+	CreatedAt        time.Time       `json:"created_at"`
 }
 
-// RewardRequest API request for creating reward
+// RewardRequest API request for creating reward. Exactly one of quantity
+// or amount_inr must be given.
 type RewardRequest struct {
-	UserID         string    `json:"user_id" binding:"required" example:"user123"`
-	StockSymbol    string    `json:"stock_symbol" binding:"required" example:"RELIANCE"`
-	Quantity       float64   `json:"quantity" binding:"required,gt=0" example:"10.5"`
-	RewardedAt     time.Time `json:"rewarded_at" example:"2025-11-10T10:00:00Z"`
-	IdempotencyKey string    `json:"idempotency_key" example:"reward-123-456"`
+	UserID         string              `json:"user_id" binding:"required" example:"user123"`
+	StockSymbol    string              `json:"stock_symbol" binding:"required" example:"RELIANCE"`
+	Quantity       decimal.Decimal     `json:"quantity" example:"10.5"`
+	AmountINR      decimal.NullDecimal `json:"amount_inr" example:"500"` // alternative to quantity
+	RewardedAt     time.Time           `json:"rewarded_at" example:"2025-11-10T10:00:00Z"`
+	IdempotencyKey string              `json:"idempotency_key" example:"reward-123-456"`
+	CampaignID     *int64              `json:"campaign_id" example:"1"`
+}
+
+// ReverseRewardRequest API request for reversing a reward
+type ReverseRewardRequest struct {
+	Reason string `json:"reason" binding:"required" example:"Sent to wrong user"`
+}
+
+// CorporateActionRequest API request for recording a split or bonus issue
+type CorporateActionRequest struct {
+	StockSymbol string `json:"stock_symbol" binding:"required" example:"RELIANCE"`
+	ActionType  string `json:"action_type" binding:"required,oneof=SPLIT BONUS" example:"SPLIT"`
+	RatioOld    int64  `json:"ratio_old" binding:"required,gt=0" example:"1"`
+	RatioNew    int64  `json:"ratio_new" binding:"required,gt=0" example:"5"`
+	ExDate      string `json:"ex_date" binding:"required" example:"2025-11-20"`
+}
+
+// CorporateActionResponse API response for a recorded corporate action
+type CorporateActionResponse struct {
+	Action          CorporateAction `json:"action"`
+	AdjustedRewards int             `json:"adjusted_rewards" example:"42"`
+}
+
+// DividendRequest API request for declaring a cash dividend
+type DividendRequest struct {
+	StockSymbol    string          `json:"stock_symbol" binding:"required" example:"TCS"`
+	AmountPerShare decimal.Decimal `json:"amount_per_share" example:"24.00"`
+	RecordDate     string          `json:"record_date" binding:"required" example:"2025-11-20"`
+}
+
+// DividendResponse API response for a declared dividend
+type DividendResponse struct {
+	Dividend Dividend `json:"dividend"`
+	Payouts  int      `json:"payouts" example:"42"`
+}
+
+// DividendHistoryResponse API response for a user's dividend history
+type DividendHistoryResponse struct {
+	UserID     string           `json:"user_id" example:"user123"`
+	Payouts    []DividendPayout `json:"payouts"`
+	TotalGross decimal.Decimal  `json:"total_gross" example:"1200.00"`
+	TotalTDS   decimal.Decimal  `json:"total_tds" example:"120.00"`
+	TotalNet   decimal.Decimal  `json:"total_net" example:"1080.00"`
+}
+
+// FeeScheduleRequest API request for publishing a new fee schedule version
+type FeeScheduleRequest struct {
+	Name          string                `json:"name" binding:"required" example:"FY26 schedule"`
+	EffectiveFrom time.Time             `json:"effective_from" binding:"required" example:"2025-12-01T00:00:00+05:30"`
+	Components    []FeeComponentRequest `json:"components" binding:"required,min=1,dive"`
+}
+
+// FeeComponentRequest is one component of a FeeScheduleRequest
+type FeeComponentRequest struct {
+	Code      string              `json:"code" binding:"required" example:"BROKERAGE"`
+	Name      string              `json:"name" binding:"required" example:"Brokerage"`
+	Rate      decimal.Decimal     `json:"rate" example:"0.005"`
+	Basis     string              `json:"basis" binding:"required" example:"TRADE_VALUE"`
+	MinAmount decimal.NullDecimal `json:"min_amount"`
+	MaxAmount decimal.NullDecimal `json:"max_amount" example:"20"`
+}
+
+// SecurityRequest API request for creating or updating a security
+type SecurityRequest struct {
+	Symbol   string          `json:"symbol" example:"RELIANCE"`
+	ISIN     string          `json:"isin" binding:"required,len=12" example:"INE002A01018"`
+	Name     string          `json:"name" binding:"required" example:"Reliance Industries Ltd"`
+	Exchange string          `json:"exchange" binding:"required,oneof=NSE BSE" example:"NSE"`
+	LotSize  decimal.Decimal `json:"lot_size" example:"0.000001"`
+	Status   string          `json:"status" binding:"omitempty,oneof=ACTIVE SUSPENDED DELISTED" example:"ACTIVE"`
+}
+
+// SecurityImportResponse API response for a securities CSV import
+type SecurityImportResponse struct {
+	Created int `json:"created" example:"8"`
+	Updated int `json:"updated" example:"2"`
+}
+
+// APIKeyRequest API request for creating a key with partner scopes, admin roles, or both
+type APIKeyRequest struct {
+	Name   string   `json:"name" binding:"required,max=100" example:"partner-rewards"`
+	Scopes []string `json:"scopes" example:"rewards:write"`
+	Roles  []string `json:"roles" example:"auditor"`
+}
+
+// APIKeyResponse API response for a new key; Key is shown only this once
+type APIKeyResponse struct {
+	APIKey
+	Key string `json:"key" example:"stk_3f9a0c1e5b7d..."`
+}
+
+// PriceOverrideRequest API request for setting a price by hand, e.g. to correct a bad quote
+type PriceOverrideRequest struct {
+	Price      decimal.Decimal `json:"price" example:"2450.75"`
+	ObservedAt *time.Time      `json:"observed_at" example:"2025-11-10T10:00:00Z"` // defaults to now
+}
+
+// CampaignRequest API request for creating a reward campaign
+type CampaignRequest struct {
+	Name               string              `json:"name" binding:"required" example:"Diwali 2025"`
+	StartsAt           time.Time           `json:"starts_at" binding:"required" example:"2025-10-20T00:00:00+05:30"`
+	EndsAt             *time.Time          `json:"ends_at" example:"2025-11-05T00:00:00+05:30"`
+	BudgetINR          decimal.Decimal     `json:"budget_inr" example:"500000"`
+	PerUserQuantityCap decimal.NullDecimal `json:"per_user_quantity_cap" example:"5"`
+	PerUserINRCap      decimal.NullDecimal `json:"per_user_inr_cap" example:"10000"`
+	AllowedSymbols     []string            `json:"allowed_symbols" example:"RELIANCE,TCS"`
+}
+
+// CampaignReport API response for a campaign's spend, read from the ledger
+type CampaignReport struct {
+	Campaign        Campaign        `json:"campaign"`
+	RewardCount     int64           `json:"reward_count" example:"120"`
+	UserCount       int64           `json:"user_count" example:"95"`
+	StockValue      decimal.Decimal `json:"stock_value" example:"240000.00"`
+	Fees            []FeeItem       `json:"fees"`
+	FeeTotal        decimal.Decimal `json:"fee_total" example:"1680.00"`
+	TotalSpend      decimal.Decimal `json:"total_spend" example:"241680.00"`
+	RemainingBudget decimal.Decimal `json:"remaining_budget" example:"258320.00"`
 }
 
 // RewardResponse API response for reward creation
 type RewardResponse struct {
-	ID           int64     `json:"id" example:"1"`
-	UserID       string    `json:"user_id" example:"user123"`
-	StockSymbol  string    `json:"stock_symbol" example:"RELIANCE"`
-	Quantity     float64   `json:"quantity" example:"10.5"`
-	RewardedAt   time.Time `json:"rewarded_at" example:"2025-11-10T10:00:00Z"`
-	CurrentPrice float64   `json:"current_price" example:"2450.75"`
-	CurrentValue float64   `json:"current_value" example:"25732.88"`
+	ID           int64               `json:"id" example:"1"`
+	UserID       string              `json:"user_id" example:"user123"`
+	StockSymbol  string              `json:"stock_symbol" example:"RELIANCE"`
+	Quantity     decimal.Decimal     `json:"quantity" example:"10.5"`
+	AmountINR    decimal.NullDecimal `json:"amount_inr" example:"500"`
+	Residue      decimal.NullDecimal `json:"residue" example:"0.03"`
+	RewardedAt   time.Time           `json:"rewarded_at" example:"2025-11-10T10:00:00Z"`
+	CurrentPrice decimal.Decimal     `json:"current_price" example:"2450.75"`
+	CurrentValue decimal.Decimal     `json:"current_value" example:"25732.88"`
+	PriceAsOf    *time.Time          `json:"price_as_of" example:"2025-11-10T09:00:00Z"`
+	PriceStale   bool                `json:"price_stale" example:"false"`
+	Fees         *FeeBreakdown       `json:"fees,omitempty"`
+	CampaignID   *int64              `json:"campaign_id,omitempty" example:"1"`
+}
+
+// Batch reward modes
+const (
+	BatchModeAllOrNothing = "all_or_nothing"
+	BatchModeBestEffort   = "best_effort"
+)
+
+// Batch reward row statuses
+const (
+	BatchRowCreated    = "created"
+	BatchRowReplayed   = "replayed"
+	BatchRowFailed     = "failed"
+	BatchRowRolledBack = "rolled_back"
+)
+
+// BatchRewardResult outcome of one row of a batch reward request
+type BatchRewardResult struct {
+	Row            int             `json:"row" example:"1"`
+	IdempotencyKey string          `json:"idempotency_key,omitempty" example:"reward-123-456"`
+	Status         string          `json:"status" example:"created"`
+	Reward         *RewardResponse `json:"reward,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	Err            error           `json:"-"`
+}
+
+// BatchRewardResponse API response for batch reward creation
+type BatchRewardResponse struct {
+	Mode      string              `json:"mode" example:"all_or_nothing"`
+	Committed bool                `json:"committed" example:"true"`
+	Total     int                 `json:"total" example:"2"`
+	Created   int                 `json:"created" example:"1"`
+	Replayed  int                 `json:"replayed" example:"1"`
+	Failed    int                 `json:"failed" example:"0"`
+	Results   []BatchRewardResult `json:"results"`
 }
 
 // TodayStocksResponse API response for today's stocks
@@ -81,34 +710,132 @@ type HistoricalINRResponse struct {
 
 // DailyINRValue represents INR value for a specific day
 type DailyINRValue struct {
-	Date       string  `json:"date" example:"2025-11-09"`
-	TotalValue float64 `json:"total_value" example:"125000.50"`
+	Date       string          `json:"date" example:"2025-11-09"`
+	TotalValue decimal.Decimal `json:"total_value" example:"125000.50"`
 }
 
 // StatsResponse API response for user stats
 type StatsResponse struct {
 	UserID                string          `json:"user_id" example:"user123"`
 	TodayRewards          []StockQuantity `json:"today_rewards"`
-	CurrentPortfolioValue float64         `json:"current_portfolio_value" example:"250000.75"`
+	CurrentPortfolioValue decimal.Decimal `json:"current_portfolio_value" example:"250000.75"`
+	PricesAsOf            *time.Time      `json:"prices_as_of" example:"2025-11-10T10:00:00Z"` // oldest price used
+	PriceStale            bool            `json:"price_stale" example:"false"`
+	StaleSymbols          []string        `json:"stale_symbols,omitempty"`
+}
+
+// Health check statuses
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// HealthCheck is one dependency's status in a readiness report
+type HealthCheck struct {
+	Status string      `json:"status" example:"ok"`
+	Error  string      `json:"error,omitempty"`
+	Detail interface{} `json:"detail,omitempty"`
+}
+
+// ReadinessResponse API response for /readyz; Status is fail if any check failed
+type ReadinessResponse struct {
+	Status string                 `json:"status" example:"ok"`
+	Checks map[string]HealthCheck `json:"checks"`
+}
+
+// PriceUpdaterStatus describes the background price updater's recent runs
+type PriceUpdaterStatus struct {
+	LastRunAt     *time.Time `json:"last_run_at"`
+	LastSuccessAt *time.Time `json:"last_success_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	Symbols       int        `json:"symbols" example:"10"` // symbols in the last price update
+	Failed        int        `json:"failed" example:"0"`   // symbols the provider could not price
 }
 
 // StockQuantity represents quantity by stock symbol
 type StockQuantity struct {
-	StockSymbol string  `json:"stock_symbol" example:"RELIANCE"`
-	Quantity    float64 `json:"quantity" example:"15.5"`
+	StockSymbol string          `json:"stock_symbol" example:"RELIANCE"`
+	Quantity    decimal.Decimal `json:"quantity" example:"15.5"`
 }
 
 // PortfolioResponse API response for portfolio
 type PortfolioResponse struct {
 	UserID     string          `json:"user_id" example:"user123"`
 	Holdings   []HoldingDetail `json:"holdings"`
-	TotalValue float64         `json:"total_value" example:"250000.75"`
+	TotalValue decimal.Decimal `json:"total_value" example:"250000.75"`
+	PriceStale bool            `json:"price_stale" example:"false"` // true if any holding was valued at a stale price
 }
 
 // HoldingDetail represents individual stock holding
 type HoldingDetail struct {
-	StockSymbol  string  `json:"stock_symbol" example:"RELIANCE"`
-	TotalShares  float64 `json:"total_shares" example:"25.5"`
-	CurrentPrice float64 `json:"current_price" example:"2450.75"`
-	CurrentValue float64 `json:"current_value" example:"62489.13"`
+	StockSymbol  string          `json:"stock_symbol" example:"RELIANCE"`
+	TotalShares  decimal.Decimal `json:"total_shares" example:"25.5"`
+	CurrentPrice decimal.Decimal `json:"current_price" example:"2450.75"`
+	CurrentValue decimal.Decimal `json:"current_value" example:"62489.13"`
+	PriceAsOf    *time.Time      `json:"price_as_of" example:"2025-11-10T10:00:00Z"`
+	PriceStale   bool            `json:"price_stale" example:"false"`
+}
+
+// PriceHistoryResponse API response for a symbol's price history
+type PriceHistoryResponse struct {
+	StockSymbol string         `json:"stock_symbol" example:"RELIANCE"`
+	Prices      []PriceHistory `json:"prices"`
+}
+
+// AuditLogResponse API response for an audit log query. NextBeforeID is set
+// when there are more entries; pass it as before_id to get them.
+type AuditLogResponse struct {
+	Entries      []AuditLog `json:"entries"`
+	NextBeforeID *int64     `json:"next_before_id,omitempty" example:"1234"`
+}
+
+// TrialBalanceLine is one account's debit and credit totals
+type TrialBalanceLine struct {
+	AccountCode string          `json:"account_code" example:"COMPANY_CASH"`
+	AccountName string          `json:"account_name" example:"Company cash"`
+	AccountType string          `json:"account_type" example:"ASSET"`
+	Debit       decimal.Decimal `json:"debit" example:"0.00"`
+	Credit      decimal.Decimal `json:"credit" example:"25908.11"`
+	Balance     decimal.Decimal `json:"balance" example:"-25908.11"` // in the account's normal direction
+}
+
+// TrialBalanceResponse API response for the trial balance
+type TrialBalanceResponse struct {
+	AsOf        string             `json:"as_of" example:"2025-11-10"`
+	Lines       []TrialBalanceLine `json:"lines"`
+	TotalDebit  decimal.Decimal    `json:"total_debit" example:"25908.11"`
+	TotalCredit decimal.Decimal    `json:"total_credit" example:"25908.11"`
+	Balanced    bool               `json:"balanced" example:"true"`
+}
+
+// AccountBalanceResponse API response for a single account balance
+type AccountBalanceResponse struct {
+	Account Account         `json:"account"`
+	AsOf    string          `json:"as_of" example:"2025-11-10"`
+	Balance decimal.Decimal `json:"balance" example:"25732.88"`
+}
+
+// GeneralLedgerLine is one posting in an account's general ledger
+type GeneralLedgerLine struct {
+	EntryID        int64           `json:"entry_id" example:"17"`
+	JournalID      int64           `json:"journal_id" example:"5"`
+	Reference      string          `json:"reference" example:"reward:42"`
+	PostedAt       time.Time       `json:"posted_at"`
+	EntryType      string          `json:"entry_type" example:"STOCK_CREDIT"`
+	UserID         string          `json:"user_id,omitempty" example:"user123"`
+	StockSymbol    string          `json:"stock_symbol,omitempty" example:"RELIANCE"`
+	Description    string          `json:"description"`
+	Debit          decimal.Decimal `json:"debit" example:"25732.88"`
+	Credit         decimal.Decimal `json:"credit" example:"0.00"`
+	RunningBalance decimal.Decimal `json:"running_balance" example:"25732.88"`
+}
+
+// GeneralLedgerResponse API response for an account's general ledger
+type GeneralLedgerResponse struct {
+	Account        Account             `json:"account"`
+	From           string              `json:"from" example:"2025-11-01"`
+	To             string              `json:"to" example:"2025-11-10"`
+	OpeningBalance decimal.Decimal     `json:"opening_balance" example:"0.00"`
+	Lines          []GeneralLedgerLine `json:"lines"`
+	ClosingBalance decimal.Decimal     `json:"closing_balance" example:"25732.88"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+// Rounding rules: INR amounts and prices are rounded to paise, share
+// quantities to 6 decimal places (the precision of the quantity columns).
+// Both use banker's rounding (round half to even) so repeated rounding
+// does not bias ledger totals.
+const (
+	AmountPlaces   = 2
+	QuantityPlaces = 6
+)
+
+// RoundAmount rounds an INR amount to paise
+func RoundAmount(d decimal.Decimal) decimal.Decimal {
+	return d.RoundBank(AmountPlaces)
+}
+
+// RoundQuantity rounds a share quantity to 6 decimal places
+func RoundQuantity(d decimal.Decimal) decimal.Decimal {
+	return d.RoundBank(QuantityPlaces)
+}
+
+// QuantityForAmount converts an INR amount to the share quantity it buys at
+// price. The quantity is truncated, not rounded, so its value never exceeds
+// the amount; the difference is the rounding residue.
+func QuantityForAmount(amount, price decimal.Decimal) decimal.Decimal {
+	quantity, _ := amount.QuoRem(price, QuantityPlaces)
+	return quantity
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestRoundAmount(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"1.004", "1"},
+		{"1.0051", "1.01"},
+		{"1.005", "1"}, // half to even
+		{"1.015", "1.02"},
+		{"2.675", "2.68"},
+		{"-1.005", "-1"},
+		{"-1.015", "-1.02"},
+		{"0.004999", "0"},
+		{"99999999999999.995", "100000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := RoundAmount(decimal.RequireFromString(tt.in)); !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Fatalf("RoundAmount(%s) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundQuantity(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"1.2345674", "1.234567"},
+		{"1.2345665", "1.234566"}, // half to even
+		{"1.2345675", "1.234568"},
+		{"0.0000005", "0"},
+		{"0.0000015", "0.000002"},
+		{"-0.0000025", "-0.000002"},
+		{"10", "10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := RoundQuantity(decimal.RequireFromString(tt.in)); !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Fatalf("RoundQuantity(%s) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuantityForAmount(t *testing.T) {
+	tests := []struct {
+		name, amount, price, want string
+	}{
+		{"exact", "1000", "250", "4"},
+		{"truncated, not rounded up", "500", "3000", "0.166666"},
+		{"repeating digits", "100", "3", "33.333333"},
+		{"just over half a micro-share", "0.01", "15000", "0"}, // 0.00000066..
+		{"exactly one micro-share", "0.01", "10000", "0.000001"},
+		{"amount too small for any quantity", "0.01", "100000", "0"},
+		{"fractional price", "500", "2452.4", "0.203881"},
+		{"just under a micro-share boundary", "19999996", "10000000", "1.999999"},
+		{"within rounding distance of a whole share", "5000000", "5000000.0001", "0.999999"}, // 0.99999999998
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount := decimal.RequireFromString(tt.amount)
+			price := decimal.RequireFromString(tt.price)
+			got := QuantityForAmount(amount, price)
+			if !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Fatalf("QuantityForAmount(%s, %s) = %s, want %s", tt.amount, tt.price, got, tt.want)
+			}
+			if got.Mul(price).GreaterThan(amount) {
+				t.Fatalf("quantity %s at %s costs more than %s", got, tt.price, tt.amount)
+			}
+			if got.Exponent() < -QuantityPlaces {
+				t.Fatalf("quantity %s has more than %d decimal places", got, QuantityPlaces)
+			}
+		})
+	}
+}
@@ -2,18 +2,97 @@ package routes
 
 import (
 	"stocky/handlers"
+	"stocky/middleware"
+	"stocky/models"
+	"stocky/services"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"gorm.io/gorm"
 )
 
-func SetupRoutes(router *gin.RouterGroup, db *gorm.DB) {
-	handler := handlers.NewRewardHandler(db)
+func SetupRoutes(router *gin.RouterGroup, db *gorm.DB, priceService *services.StockPriceService, corporateActionService *services.CorporateActionService, rewardService *services.RewardService, campaignService *services.CampaignService, securityService *services.SecurityService, feeService *services.FeeService, ledgerService *services.LedgerService, authService *services.AuthService) {
+	handler := handlers.NewRewardHandler(db, priceService, corporateActionService, rewardService)
 
-	// Reward endpoints
-	router.POST("/reward", handler.CreateReward)
-	router.GET("/today-stocks/:userId", handler.GetTodayStocks)
-	router.GET("/historical-inr/:userId", handler.GetHistoricalINR)
-	router.GET("/stats/:userId", handler.GetStats)
-	router.GET("/portfolio/:userId", handler.GetPortfolio)
+	// Reward endpoints, for partner systems
+	rewards := router.Group("", middleware.RequireAPIKey(authService, models.ScopeRewardsWrite))
+	rewards.POST("/reward", handler.CreateReward)
+	rewards.POST("/rewards/batch", handler.CreateRewardBatch)
+
+	// User endpoints: a user's own token, or a partner key that can read any user
+	users := router.Group("", middleware.RequireUserOrAPIKey(authService, models.ScopeUsersRead))
+	users.GET("/today-stocks/:userId", handler.GetTodayStocks)
+	users.GET("/historical-inr/:userId", handler.GetHistoricalINR)
+	users.GET("/stats/:userId", handler.GetStats)
+	users.GET("/portfolio/:userId", handler.GetPortfolio)
+
+	corporateActionHandler := handlers.NewCorporateActionHandler(corporateActionService)
+	users.GET("/dividends/:userId", corporateActionHandler.GetDividendHistory)
+
+	// Price endpoints
+	priceHandler := handlers.NewPriceHandler(priceService)
+	prices := router.Group("", middleware.RequireUserOrAPIKey(authService, models.ScopePricesRead))
+	prices.GET("/prices/:symbol/history", priceHandler.GetPriceHistory)
+
+	// Admin endpoints: any admin role can read; changes need the owning role
+	admin := router.Group("/admin", middleware.RequireAdmin(authService))
+	ops := middleware.RequireRole(models.RoleOps)
+	finance := middleware.RequireRole(models.RoleFinance)
+
+	admin.POST("/corporate-actions", ops, corporateActionHandler.CreateCorporateAction)
+	admin.GET("/corporate-actions", corporateActionHandler.ListCorporateActions)
+	admin.POST("/dividends", finance, corporateActionHandler.DeclareDividend)
+	admin.GET("/dividends", corporateActionHandler.ListDividends)
+
+	admin.POST("/rewards/:id/reverse", finance, handler.ReverseReward)
+
+	campaignHandler := handlers.NewCampaignHandler(campaignService)
+	admin.POST("/campaigns", finance, campaignHandler.CreateCampaign)
+	admin.GET("/campaigns", campaignHandler.ListCampaigns)
+	admin.GET("/campaigns/:id/report", campaignHandler.GetCampaignReport)
+
+	securityHandler := handlers.NewSecurityHandler(securityService)
+	admin.POST("/securities", ops, securityHandler.CreateSecurity)
+	admin.POST("/securities/import", ops, securityHandler.ImportSecurities)
+	admin.GET("/securities", securityHandler.ListSecurities)
+	admin.GET("/securities/:symbol", securityHandler.GetSecurity)
+	admin.PUT("/securities/:symbol", ops, securityHandler.UpdateSecurity)
+	admin.DELETE("/securities/:symbol", ops, securityHandler.DeleteSecurity)
+
+	admin.POST("/prices/bhavcopy", ops, priceHandler.ImportBhavcopy)
+	admin.PUT("/prices/:symbol", ops, priceHandler.OverridePrice)
+
+	feeHandler := handlers.NewFeeHandler(feeService)
+	admin.POST("/fee-schedules", finance, feeHandler.CreateFeeSchedule)
+	admin.GET("/fee-schedules", feeHandler.ListFeeSchedules)
+	admin.GET("/fee-schedules/effective", feeHandler.GetEffectiveFeeSchedule)
+
+	reportHandler := handlers.NewReportHandler(ledgerService)
+	admin.GET("/reports/trial-balance", reportHandler.GetTrialBalance)
+	admin.GET("/accounts", reportHandler.ListAccounts)
+	admin.GET("/accounts/:code/balance", reportHandler.GetAccountBalance)
+	admin.GET("/accounts/:code/ledger", reportHandler.GetGeneralLedger)
+	admin.GET("/ledger/verify", reportHandler.VerifyLedger)
+	admin.GET("/ledger/checkpoints", reportHandler.ListLedgerCheckpoints)
+
+	apiKeyHandler := handlers.NewAPIKeyHandler(authService)
+	admin.POST("/api-keys", ops, apiKeyHandler.CreateAPIKey)
+	admin.GET("/api-keys", apiKeyHandler.ListAPIKeys)
+	admin.DELETE("/api-keys/:id", ops, apiKeyHandler.RevokeAPIKey)
+
+	auditHandler := handlers.NewAuditHandler(services.NewAuditService(db))
+	admin.GET("/audit-logs", auditHandler.ListAuditLogs)
+}
+
+// SetupHealthRoutes registers the liveness and readiness probes at the root
+// of the server, outside the versioned API
+func SetupHealthRoutes(router *gin.Engine, healthService *services.HealthService) {
+	healthHandler := handlers.NewHealthHandler(healthService)
+	router.GET("/healthz", healthHandler.Liveness)
+	router.GET("/readyz", healthHandler.Readiness)
+}
+
+// SetupMetricsRoutes serves Prometheus metrics at /metrics
+func SetupMetricsRoutes(router *gin.Engine) {
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 }
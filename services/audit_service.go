@@ -0,0 +1,80 @@
+package services
+
+import (
+	"stocky/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Audit log page sizes
+const (
+	DefaultAuditLimit = 100
+	MaxAuditLimit     = 1000
+)
+
+// AuditFilter narrows an audit log query. Empty fields match everything.
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	EntityType string
+	EntityID   string
+	RequestID  string
+	From       time.Time // inclusive
+	To         time.Time // exclusive
+	BeforeID   int64     // for paging: only entries older than this ID
+	Limit      int
+}
+
+type AuditService struct {
+	db *gorm.DB
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// List returns matching audit entries, newest first. If more entries match
+// than the limit, the returned cursor is the BeforeID for the next page.
+func (s *AuditService) List(filter AuditFilter) ([]models.AuditLog, *int64, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultAuditLimit
+	}
+	if filter.Limit > MaxAuditLimit {
+		filter.Limit = MaxAuditLimit
+	}
+
+	query := s.db.Model(&models.AuditLog{})
+	for column, value := range map[string]string{
+		"actor":       filter.Actor,
+		"action":      filter.Action,
+		"entity_type": filter.EntityType,
+		"entity_id":   filter.EntityID,
+		"request_id":  filter.RequestID,
+	} {
+		if value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	if filter.BeforeID > 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+
+	var entries []models.AuditLog
+	if err := query.Order("id DESC").Limit(filter.Limit + 1).Find(&entries).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var next *int64
+	if len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+		next = &entries[filter.Limit-1].ID
+	}
+	return entries, next, nil
+}
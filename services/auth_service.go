@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"stocky/audit"
+	"stocky/config"
+	"stocky/models"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidAPIKeyRequest = errors.New("invalid API key request")
+	ErrAPIKeyNotFound       = errors.New("API key not found")
+	ErrInvalidAPIKey        = errors.New("invalid or revoked API key")
+	ErrInvalidToken         = errors.New("invalid token")
+	ErrGrantNotHeld         = errors.New("permission not held by the caller")
+	validScopes             = []string{models.ScopeRewardsWrite, models.ScopeUsersRead, models.ScopePricesRead}
+	validRoles              = []string{models.RoleOps, models.RoleFinance, models.RoleAuditor}
+)
+
+const (
+	apiKeyPrefix = "stk_"
+	// lastUsedResolution limits how often a key's last_used_at is written
+	lastUsedResolution = time.Minute
+)
+
+type AuthService struct {
+	db          *gorm.DB
+	jwtSecret   []byte
+	jwtIssuer   string
+	jwtAudience string
+}
+
+func NewAuthService(db *gorm.DB, cfg *config.Config) *AuthService {
+	if cfg.JWTSecret == "" {
+		logrus.Warn("JWT_SECRET is not set; user tokens will be refused")
+	}
+	return &AuthService{
+		db:          db,
+		jwtSecret:   []byte(cfg.JWTSecret),
+		jwtIssuer:   cfg.JWTIssuer,
+		jwtAudience: cfg.JWTAudience,
+	}
+}
+
+// CreateAPIKey generates a key with the given partner scopes and admin
+// roles and stores its hash. The returned key is the only copy. grantor is
+// the caller issuing the key, who may only grant scopes and roles they hold;
+// nil is the command-line tool, which can grant anything.
+func (s *AuthService) CreateAPIKey(ctx context.Context, grantor *models.Principal, name string, scopes, roles []string) (*models.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name is required", ErrInvalidAPIKeyRequest)
+	}
+	if len(scopes) == 0 && len(roles) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope or role is required", ErrInvalidAPIKeyRequest)
+	}
+	for _, scope := range scopes {
+		if !containsString(validScopes, scope) {
+			return nil, "", fmt.Errorf("%w: unknown scope %q, must be one of %s", ErrInvalidAPIKeyRequest, scope, strings.Join(validScopes, ", "))
+		}
+	}
+	for _, role := range roles {
+		if !containsString(validRoles, role) {
+			return nil, "", fmt.Errorf("%w: unknown role %q, must be one of %s", ErrInvalidAPIKeyRequest, role, strings.Join(validRoles, ", "))
+		}
+	}
+
+	if err := checkGrant(grantor, scopes, roles); err != nil {
+		return nil, "", err
+	}
+
+	key := apiKeyPrefix + randomHex(32)
+	apiKey := &models.APIKey{
+		Name:    name,
+		Prefix:  key[:len(apiKeyPrefix)+8],
+		KeyHash: hashAPIKey(key),
+		Scopes:  scopes,
+		Roles:   roles,
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(apiKey).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditAPIKeyCreate, "api_key", apiKey.ID, nil, apiKey)
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create API key: %w", err)
+	}
+	logrus.Infof("Created API key %d (%s) with scopes [%s] and roles [%s]", apiKey.ID, name, strings.Join(scopes, ", "), strings.Join(roles, ", "))
+	return apiKey, key, nil
+}
+
+// checkGrant refuses scopes and roles the grantor doesn't hold, so an admin
+// can't mint a key more powerful than their own
+func checkGrant(grantor *models.Principal, scopes, roles []string) error {
+	if grantor == nil {
+		return nil
+	}
+	for _, scope := range scopes {
+		if !grantor.HasScope(scope) {
+			return fmt.Errorf("%w: scope %s", ErrGrantNotHeld, scope)
+		}
+	}
+	for _, role := range roles {
+		if !grantor.HasRole(role) {
+			return fmt.Errorf("%w: role %s", ErrGrantNotHeld, role)
+		}
+	}
+	return nil
+}
+
+// ListAPIKeys returns all keys, including revoked ones, newest first
+func (s *AuthService) ListAPIKeys() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := s.db.Order("id DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey stops a key from authenticating. Revoking a revoked key is a
+// no-op. caller may only revoke keys they could have issued; nil is the
+// command-line tool, which can revoke any key.
+func (s *AuthService) RevokeAPIKey(ctx context.Context, caller *models.Principal, id int64) (*models.APIKey, error) {
+	var key models.APIKey
+	err := s.db.First(&key, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkGrant(caller, key.Scopes, key.Roles); err != nil {
+		return nil, fmt.Errorf("cannot revoke API key %d: %w", key.ID, err)
+	}
+	if key.RevokedAt == nil {
+		before := key
+		now := time.Now()
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&key).Update("revoked_at", now).Error; err != nil {
+				return err
+			}
+			key.RevokedAt = &now
+			return audit.Record(tx, models.AuditAPIKeyRevoke, "api_key", key.ID, before, key)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to revoke API key: %w", err)
+		}
+		logrus.Infof("Revoked API key %d (%s)", key.ID, key.Name)
+	}
+	return &key, nil
+}
+
+// AuthenticateAPIKey returns the principal for an active key
+func (s *AuthService) AuthenticateAPIKey(key string) (*models.Principal, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var apiKey models.APIKey
+	err := s.db.Where("key_hash = ? AND revoked_at IS NULL", hashAPIKey(key)).First(&apiKey).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > lastUsedResolution {
+		if err := s.db.Model(&apiKey).UpdateColumn("last_used_at", now).Error; err != nil {
+			logrus.Warnf("Failed to record use of API key %d: %v", apiKey.ID, err)
+		}
+	}
+
+	return &models.Principal{
+		Kind:     models.PrincipalAPIKey,
+		Subject:  apiKey.Name,
+		APIKeyID: apiKey.ID,
+		Scopes:   apiKey.Scopes,
+		Roles:    apiKey.Roles,
+	}, nil
+}
+
+// AuthenticateToken verifies an end user's JWT and returns a principal
+// whose subject is the user ID. Tokens must be HS256, unexpired and carry a
+// subject; issuer and audience are checked when configured.
+func (s *AuthService) AuthenticateToken(token string) (*models.Principal, error) {
+	if len(s.jwtSecret) == 0 {
+		return nil, fmt.Errorf("%w: user tokens are not accepted", ErrInvalidToken)
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	}
+	if s.jwtIssuer != "" {
+		options = append(options, jwt.WithIssuer(s.jwtIssuer))
+	}
+	if s.jwtAudience != "" {
+		options = append(options, jwt.WithAudience(s.jwtAudience))
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return s.jwtSecret, nil
+	}, options...); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidToken)
+	}
+
+	return &models.Principal{Kind: models.PrincipalUser, Subject: claims.Subject}, nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"errors"
+	"stocky/models"
+	"testing"
+)
+
+func TestCheckGrant(t *testing.T) {
+	ops := &models.Principal{Kind: models.PrincipalAPIKey, Roles: []string{models.RoleOps}}
+	opsFinance := &models.Principal{Kind: models.PrincipalAPIKey, Roles: []string{models.RoleOps, models.RoleFinance}}
+	opsPartner := &models.Principal{Kind: models.PrincipalAPIKey, Scopes: []string{models.ScopeUsersRead}, Roles: []string{models.RoleOps}}
+
+	tests := []struct {
+		name    string
+		grantor *models.Principal
+		scopes  []string
+		roles   []string
+		wantErr bool
+	}{
+		{"command line grants anything", nil, []string{models.ScopeRewardsWrite}, []string{models.RoleOps, models.RoleFinance, models.RoleAuditor}, false},
+		{"nothing to grant", ops, nil, nil, false},
+		{"own role", ops, nil, []string{models.RoleOps}, false},
+		{"ops escalates to finance", ops, nil, []string{models.RoleFinance}, true},
+		{"ops escalates to every role", ops, nil, []string{models.RoleOps, models.RoleFinance, models.RoleAuditor}, true},
+		{"subset of held roles", opsFinance, nil, []string{models.RoleFinance}, false},
+		{"ops and finance grant auditor", opsFinance, nil, []string{models.RoleAuditor}, true},
+		{"scope not held", ops, []string{models.ScopeRewardsWrite}, nil, true},
+		{"held scope", opsPartner, []string{models.ScopeUsersRead}, nil, false},
+		{"held scope with one not held", opsPartner, []string{models.ScopeUsersRead, models.ScopeRewardsWrite}, nil, true},
+		{"held scope with a role not held", opsPartner, []string{models.ScopeUsersRead}, []string{models.RoleFinance}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkGrant(tt.grantor, tt.scopes, tt.roles)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("checkGrant() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrGrantNotHeld) {
+				t.Fatalf("checkGrant() error = %v, want ErrGrantNotHeld", err)
+			}
+		})
+	}
+}
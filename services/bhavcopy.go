@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"stocky/audit"
+	"stocky/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidBhavcopy = errors.New("invalid bhavcopy")
+
+// BhavcopySeries is the only series imported; other series (BE, BL, ...)
+// trade the same symbols under different settlement rules
+const BhavcopySeries = "EQ"
+
+// bhavcopyColumns maps each field to the header names used by the old and
+// new (UDiFF) NSE bhavcopy formats, lower-cased
+var bhavcopyColumns = map[string][]string{
+	"symbol": {"symbol", "tckrsymb"},
+	"series": {"series", "sctysrs"},
+	"open":   {"open", "open_price", "opnpric"},
+	"high":   {"high", "high_price", "hghpric"},
+	"low":    {"low", "low_price", "lwpric"},
+	"close":  {"close", "close_price", "clspric"},
+	"volume": {"volume", "tottrdqty", "ttl_trd_qnty", "ttltradgvol"},
+	"date":   {"date", "timestamp", "date1", "traddt"},
+}
+
+var bhavcopyDateLayouts = []string{"02-Jan-2006", "2006-01-02", "02-01-2006", "02/01/2006", "2006/01/02"}
+
+// ImportBhavcopy loads an end-of-day bhavcopy CSV. EQ rows for symbols in
+// the securities master are stored as daily OHLC, and each close is added to
+// price history at the session close. Importing a trade date again updates
+// its daily prices and appends only closes that changed, so re-running a
+// file is safe and history keeps every close published. A malformed row
+// rejects the whole file.
+func (s *StockPriceService) ImportBhavcopy(ctx context.Context, r io.Reader) (*models.BhavcopyImportResponse, error) {
+	rows, skippedSeries, err := parseBhavcopy(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var known []string
+	if err := s.db.Model(&models.Security{}).Pluck("symbol", &known).Error; err != nil {
+		return nil, err
+	}
+	isKnown := make(map[string]bool, len(known))
+	for _, symbol := range known {
+		isKnown[symbol] = true
+	}
+
+	result := &models.BhavcopyImportResponse{SkippedSeries: skippedSeries}
+	byDate := make(map[string][]models.DailyPrice)
+	for _, row := range rows {
+		if !isKnown[row.StockSymbol] {
+			result.UnknownSymbols++
+			continue
+		}
+		date := row.TradeDate.Format("2006-01-02")
+		byDate[date] = append(byDate[date], row)
+	}
+	for date := range byDate {
+		result.TradeDates = append(result.TradeDates, date)
+	}
+	sort.Strings(result.TradeDates)
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, date := range result.TradeDates {
+			prices := byDate[date]
+			closeTime := s.calendar.SessionClose(prices[0].TradeDate)
+
+			var replaced []models.DailyPrice
+			if err := tx.Where("trade_date = ?", date).Find(&replaced).Error; err != nil {
+				return err
+			}
+			var published []models.PriceHistory
+			if err := tx.Where("source = ? AND observed_at = ?", models.PriceSourceBhavcopy, closeTime).
+				Order("id").Find(&published).Error; err != nil {
+				return err
+			}
+
+			// Append a close to history only when it differs from the one
+			// already published for the day; the latest entry wins
+			history := newBhavcopyHistory(prices, published, closeTime)
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "stock_symbol"}, {Name: "trade_date"}},
+				DoUpdates: clause.AssignmentColumns([]string{"series", "open", "high", "low", "close", "volume", "updated_at"}),
+			}).CreateInBatches(&prices, 500).Error; err != nil {
+				return fmt.Errorf("failed to store daily prices for %s: %w", date, err)
+			}
+			if len(history) > 0 {
+				if err := tx.CreateInBatches(&history, 500).Error; err != nil {
+					return fmt.Errorf("failed to append price history for %s: %w", date, err)
+				}
+			}
+			for _, price := range prices {
+				if err := updateLatestPrice(tx, price.StockSymbol, price.Close, models.PriceSourceBhavcopy, closeTime); err != nil {
+					return err
+				}
+			}
+			if err := audit.Record(tx, models.AuditBhavcopyImport, "daily_prices", date, closesBySymbol(replaced), closesBySymbol(prices)); err != nil {
+				return err
+			}
+			result.Imported += len(prices)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Imported bhavcopy for %s: %d prices, %d other series, %d unknown symbols",
+		strings.Join(result.TradeDates, ", "), result.Imported, result.SkippedSeries, result.UnknownSymbols)
+	return result, nil
+}
+
+// newBhavcopyHistory returns the history entries to append for a day's
+// prices, skipping closes that match the latest one already published for
+// the same session close
+func newBhavcopyHistory(prices []models.DailyPrice, published []models.PriceHistory, closeTime time.Time) []models.PriceHistory {
+	latest := make(map[string]decimal.Decimal, len(published))
+	for _, entry := range published {
+		latest[entry.StockSymbol] = entry.Price
+	}
+
+	var history []models.PriceHistory
+	for _, price := range prices {
+		if prev, ok := latest[price.StockSymbol]; ok && prev.Equal(price.Close) {
+			continue
+		}
+		history = append(history, models.PriceHistory{
+			StockSymbol: price.StockSymbol,
+			Price:       price.Close,
+			Source:      models.PriceSourceBhavcopy,
+			ObservedAt:  closeTime,
+		})
+	}
+	return history
+}
+
+// closesBySymbol summarises a day's prices for the audit log, or returns
+// nil if there are none
+func closesBySymbol(prices []models.DailyPrice) map[string]decimal.Decimal {
+	if len(prices) == 0 {
+		return nil
+	}
+	closes := make(map[string]decimal.Decimal, len(prices))
+	for _, price := range prices {
+		closes[price.StockSymbol] = price.Close
+	}
+	return closes
+}
+
+// parseBhavcopy reads the EQ rows of a bhavcopy CSV and counts the rows of
+// other series
+func parseBhavcopy(r io.Reader) ([]models.DailyPrice, int, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: cannot read CSV header: %v", ErrInvalidBhavcopy, err)
+	}
+	index := make(map[string]int)
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	columns := make(map[string]int)
+	for field, names := range bhavcopyColumns {
+		for _, name := range names {
+			if i, ok := index[name]; ok {
+				columns[field] = i
+				break
+			}
+		}
+		if _, ok := columns[field]; !ok && field != "series" && field != "volume" {
+			return nil, 0, fmt.Errorf("%w: no %s column in header", ErrInvalidBhavcopy, field)
+		}
+	}
+
+	var rows []models.DailyPrice
+	skipped := 0
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrInvalidBhavcopy, err)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		series := strings.ToUpper(field("series"))
+		if series != "" && series != BhavcopySeries {
+			skipped++
+			continue
+		}
+
+		row := models.DailyPrice{
+			StockSymbol: strings.ToUpper(field("symbol")),
+			Series:      series,
+		}
+		if row.StockSymbol == "" {
+			return nil, 0, fmt.Errorf("%w: line %d: symbol is empty", ErrInvalidBhavcopy, line)
+		}
+		for name, target := range map[string]*decimal.Decimal{
+			"open": &row.Open, "high": &row.High, "low": &row.Low, "close": &row.Close,
+		} {
+			value, err := decimal.NewFromString(field(name))
+			if err != nil || !value.IsPositive() {
+				return nil, 0, fmt.Errorf("%w: line %d: %s %q is not a positive number", ErrInvalidBhavcopy, line, name, field(name))
+			}
+			*target = models.RoundAmount(value)
+		}
+		if row.Low.GreaterThan(row.High) || row.Close.LessThan(row.Low) || row.Close.GreaterThan(row.High) {
+			return nil, 0, fmt.Errorf("%w: line %d: close %s is outside low %s and high %s", ErrInvalidBhavcopy, line, row.Close, row.Low, row.High)
+		}
+		if value := field("volume"); value != "" {
+			if row.Volume, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return nil, 0, fmt.Errorf("%w: line %d: volume %q is not a whole number", ErrInvalidBhavcopy, line, value)
+			}
+		}
+		if row.TradeDate, err = parseBhavcopyDate(field("date")); err != nil {
+			return nil, 0, fmt.Errorf("%w: line %d: %v", ErrInvalidBhavcopy, line, err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, 0, fmt.Errorf("%w: no %s rows", ErrInvalidBhavcopy, BhavcopySeries)
+	}
+	return rows, skipped, nil
+}
+
+func parseBhavcopyDate(value string) (time.Time, error) {
+	for _, layout := range bhavcopyDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("date %q is not in a known format", value)
+}
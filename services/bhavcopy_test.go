@@ -0,0 +1,51 @@
+package services
+
+import (
+	"stocky/models"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestNewBhavcopyHistory(t *testing.T) {
+	closeTime := time.Date(2025, 11, 10, 15, 30, 0, 0, IST)
+	prices := []models.DailyPrice{
+		{StockSymbol: "RELIANCE", Close: decimal.RequireFromString("2451.30")},
+		{StockSymbol: "TCS", Close: decimal.RequireFromString("3498.00")},
+	}
+
+	tests := []struct {
+		name      string
+		published []models.PriceHistory
+		want      []string
+	}{
+		{"first import", nil, []string{"RELIANCE", "TCS"}},
+		{"same file again", []models.PriceHistory{
+			{StockSymbol: "RELIANCE", Price: decimal.RequireFromString("2451.3000")},
+			{StockSymbol: "TCS", Price: decimal.RequireFromString("3498")},
+		}, nil},
+		{"corrected close", []models.PriceHistory{
+			{StockSymbol: "RELIANCE", Price: decimal.RequireFromString("2451.30")},
+			{StockSymbol: "TCS", Price: decimal.RequireFromString("3490.00")},
+		}, []string{"TCS"}},
+		{"corrected back to the original", []models.PriceHistory{
+			{StockSymbol: "TCS", Price: decimal.RequireFromString("3498.00")},
+			{StockSymbol: "TCS", Price: decimal.RequireFromString("3490.00")},
+		}, []string{"RELIANCE", "TCS"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := newBhavcopyHistory(prices, tt.published, closeTime)
+			if len(history) != len(tt.want) {
+				t.Fatalf("appended %d entries, want %v", len(history), tt.want)
+			}
+			for i, entry := range history {
+				if entry.StockSymbol != tt.want[i] || entry.Source != models.PriceSourceBhavcopy || !entry.ObservedAt.Equal(closeTime) {
+					t.Fatalf("entry %d = %s from %s at %s, want %s from bhavcopy at %s",
+						i, entry.StockSymbol, entry.Source, entry.ObservedAt, tt.want[i], closeTime)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stocky/audit"
+	"stocky/models"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidCampaign  = errors.New("invalid campaign")
+	ErrCampaignNotFound = errors.New("campaign not found")
+	ErrCampaignRejected = errors.New("reward rejected by campaign")
+)
+
+type CampaignService struct {
+	db *gorm.DB
+}
+
+func NewCampaignService(db *gorm.DB) *CampaignService {
+	return &CampaignService{db: db}
+}
+
+// Create validates and stores a new campaign
+func (s *CampaignService) Create(ctx context.Context, campaign *models.Campaign) error {
+	if strings.TrimSpace(campaign.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidCampaign)
+	}
+	if campaign.EndsAt != nil && !campaign.EndsAt.After(campaign.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidCampaign)
+	}
+	if !campaign.BudgetINR.IsPositive() {
+		return fmt.Errorf("%w: budget_inr must be greater than 0", ErrInvalidCampaign)
+	}
+	if campaign.PerUserQuantityCap.Valid && !campaign.PerUserQuantityCap.Decimal.IsPositive() {
+		return fmt.Errorf("%w: per_user_quantity_cap must be greater than 0", ErrInvalidCampaign)
+	}
+	if campaign.PerUserINRCap.Valid && !campaign.PerUserINRCap.Decimal.IsPositive() {
+		return fmt.Errorf("%w: per_user_inr_cap must be greater than 0", ErrInvalidCampaign)
+	}
+
+	campaign.BudgetINR = models.RoundAmount(campaign.BudgetINR)
+	for i, symbol := range campaign.AllowedSymbols {
+		campaign.AllowedSymbols[i] = strings.ToUpper(strings.TrimSpace(symbol))
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(campaign).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditCampaignCreate, "campaign", campaign.ID, nil, campaign)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create campaign: %w", err)
+	}
+	return nil
+}
+
+// List returns all campaigns, newest first
+func (s *CampaignService) List() ([]models.Campaign, error) {
+	var campaigns []models.Campaign
+	if err := s.db.Order("starts_at DESC").Find(&campaigns).Error; err != nil {
+		return nil, err
+	}
+	return campaigns, nil
+}
+
+// Get returns a campaign by id
+func (s *CampaignService) Get(id int64) (*models.Campaign, error) {
+	var campaign models.Campaign
+	err := s.db.First(&campaign, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrCampaignNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// CheckReward locks the campaign row in tx and fails with
+// ErrCampaignRejected if the reward falls outside the campaign's dates or
+// symbols, or would take spend past the budget or the user past a cap.
+// Holding the lock until tx commits keeps concurrent rewards from
+// overspending together.
+func (s *CampaignService) CheckReward(tx *gorm.DB, campaignID int64, req models.RewardRequest, stockValue decimal.Decimal, fees *models.FeeBreakdown) error {
+	var campaign models.Campaign
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&campaign, campaignID).Error
+	if err == gorm.ErrRecordNotFound {
+		return fmt.Errorf("%w: campaign %d does not exist", ErrCampaignRejected, campaignID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !campaign.ActiveAt(req.RewardedAt) {
+		return fmt.Errorf("%w: %s is not running at %s", ErrCampaignRejected, campaign.Name, req.RewardedAt.Format(time.RFC3339))
+	}
+	if !campaign.AllowsSymbol(req.StockSymbol) {
+		return fmt.Errorf("%w: %s does not allow %s", ErrCampaignRejected, campaign.Name, req.StockSymbol)
+	}
+
+	spend, err := campaignCashSpend(tx, campaign.ID)
+	if err != nil {
+		return err
+	}
+	cost := stockValue.Add(fees.Total)
+	if spend.Add(cost).GreaterThan(campaign.BudgetINR) {
+		return fmt.Errorf("%w: reward costing %s exceeds remaining budget %s of %s",
+			ErrCampaignRejected, cost, campaign.BudgetINR.Sub(spend), campaign.Name)
+	}
+
+	if campaign.PerUserQuantityCap.Valid {
+		var quantity decimal.Decimal
+		if err := tx.Model(&models.StockReward{}).
+			Select("COALESCE(SUM(quantity), 0)").
+			Where("campaign_id = ? AND user_id = ? AND reversed_at IS NULL", campaign.ID, req.UserID).
+			Row().Scan(&quantity); err != nil {
+			return err
+		}
+		if quantity.Add(req.Quantity).GreaterThan(campaign.PerUserQuantityCap.Decimal) {
+			return fmt.Errorf("%w: user %s would exceed the %s share cap of %s",
+				ErrCampaignRejected, req.UserID, campaign.PerUserQuantityCap.Decimal, campaign.Name)
+		}
+	}
+
+	if campaign.PerUserINRCap.Valid {
+		var value decimal.Decimal
+		if err := campaignEntries(tx, campaign.ID).
+			Select("COALESCE(SUM(ledger_entries.amount), 0)").
+			Where("ledger_entries.account_code = ? AND stock_rewards.user_id = ?", models.AccountUserStockHoldings, req.UserID).
+			Row().Scan(&value); err != nil {
+			return err
+		}
+		if value.Add(stockValue).GreaterThan(campaign.PerUserINRCap.Decimal) {
+			return fmt.Errorf("%w: user %s would exceed the INR %s cap of %s",
+				ErrCampaignRejected, req.UserID, campaign.PerUserINRCap.Decimal, campaign.Name)
+		}
+	}
+
+	return nil
+}
+
+// Report summarises a campaign's spend from the ledger, net of reversals
+func (s *CampaignService) Report(id int64) (*models.CampaignReport, error) {
+	campaign, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.CampaignReport{Campaign: *campaign, Fees: []models.FeeItem{}}
+	if err := s.db.Model(&models.StockReward{}).
+		Select("COUNT(*), COUNT(DISTINCT user_id)").
+		Where("campaign_id = ? AND reversed_at IS NULL", id).
+		Row().Scan(&report.RewardCount, &report.UserCount); err != nil {
+		return nil, err
+	}
+
+	if err := campaignEntries(s.db, id).
+		Select("COALESCE(SUM(ledger_entries.amount), 0)").
+		Where("ledger_entries.account_code = ?", models.AccountUserStockHoldings).
+		Row().Scan(&report.StockValue); err != nil {
+		return nil, err
+	}
+
+	rows, err := campaignEntries(s.db, id).
+		Select("ledger_entries.fee_code, SUM(ledger_entries.amount)").
+		Where("ledger_entries.account_code <> ? AND ledger_entries.fee_code <> ''", models.AccountCompanyCash).
+		Group("ledger_entries.fee_code").
+		Order("ledger_entries.fee_code").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report.FeeTotal = decimal.Zero
+	for rows.Next() {
+		var item models.FeeItem
+		if err := rows.Scan(&item.Code, &item.Amount); err != nil {
+			return nil, err
+		}
+		item.Name = item.Code
+		report.Fees = append(report.Fees, item)
+		report.FeeTotal = report.FeeTotal.Add(item.Amount)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report.TotalSpend = report.StockValue.Add(report.FeeTotal)
+	report.RemainingBudget = campaign.BudgetINR.Sub(report.TotalSpend)
+	return report, nil
+}
+
+// campaignEntries scopes ledger entries to rewards given under a campaign
+func campaignEntries(db *gorm.DB, campaignID int64) *gorm.DB {
+	return db.Model(&models.LedgerEntry{}).
+		Joins("JOIN stock_rewards ON stock_rewards.id = ledger_entries.reward_id").
+		Where("stock_rewards.campaign_id = ?", campaignID)
+}
+
+// campaignCashSpend is the company cash paid out for a campaign's rewards
+// and their fees
+func campaignCashSpend(db *gorm.DB, campaignID int64) (decimal.Decimal, error) {
+	var cash decimal.Decimal
+	if err := campaignEntries(db, campaignID).
+		Select("COALESCE(SUM(ledger_entries.amount), 0)").
+		Where("ledger_entries.account_code = ?", models.AccountCompanyCash).
+		Row().Scan(&cash); err != nil {
+		return decimal.Zero, err
+	}
+	return cash.Neg(), nil
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stocky/audit"
+	"stocky/config"
+	"stocky/models"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidCorporateAction = errors.New("invalid corporate action")
+
+// symbolLockSpace is the first key of the per-symbol advisory lock taken by
+// anything that books or adjusts a symbol's holdings as of a past date
+const symbolLockSpace = 0x53594d42 // "SYMB"
+
+// QuantityAdjustment is a corporate-action quantity change to a single reward
+type QuantityAdjustment struct {
+	RewardID int64
+	Quantity decimal.Decimal
+	ExDate   time.Time // midnight IST at the start of the ex-date
+}
+
+type CorporateActionService struct {
+	db     *gorm.DB
+	cfg    *config.Config
+	ledger *LedgerService
+}
+
+func NewCorporateActionService(db *gorm.DB, cfg *config.Config, ledger *LedgerService) *CorporateActionService {
+	return &CorporateActionService{db: db, cfg: cfg, ledger: ledger}
+}
+
+// StartProcessor runs hourly to apply corporate actions and dividends whose
+// date has arrived. It returns when ctx is cancelled.
+func (s *CorporateActionService) StartProcessor(ctx context.Context) {
+	logrus.Info("Starting corporate action processor (runs every hour)")
+
+	s.ProcessDue(ctx)
+
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.ProcessDue(ctx)
+		}
+	}
+}
+
+// Record stores a corporate action and applies it immediately if its ex-date has passed
+func (s *CorporateActionService) Record(ctx context.Context, action *models.CorporateAction) (int, error) {
+	if action.RatioOld <= 0 || action.RatioNew <= 0 {
+		return 0, fmt.Errorf("%w: ratios must be positive", ErrInvalidCorporateAction)
+	}
+	if action.ActionType != models.CorporateActionSplit && action.ActionType != models.CorporateActionBonus {
+		return 0, fmt.Errorf("%w: unknown action type %q", ErrInvalidCorporateAction, action.ActionType)
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(action).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditCorporateActionCreate, "corporate_action", action.ID, nil, action)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to record corporate action: %w", err)
+	}
+
+	if action.ExDate.After(time.Now()) {
+		return 0, nil
+	}
+	return s.Process(ctx, action.ID)
+}
+
+// List returns corporate actions, newest ex-date first, optionally for one symbol
+func (s *CorporateActionService) List(symbol string) ([]models.CorporateAction, error) {
+	query := s.db.Order("ex_date DESC, id DESC")
+	if symbol != "" {
+		query = query.Where("stock_symbol = ?", symbol)
+	}
+
+	var actions []models.CorporateAction
+	if err := query.Find(&actions).Error; err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+// ProcessDue applies every unprocessed corporate action and dividend whose
+// date has arrived. Cancelling ctx stops it between actions.
+func (s *CorporateActionService) ProcessDue(ctx context.Context) {
+	var actions []models.CorporateAction
+	if err := s.db.WithContext(ctx).Where("processed_at IS NULL AND ex_date <= ?", time.Now()).
+		Order("ex_date, id").Find(&actions).Error; err != nil {
+		logrus.Errorf("Failed to fetch due corporate actions: %v", err)
+	}
+
+	for _, action := range actions {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, err := s.Process(ctx, action.ID); err != nil {
+			logrus.Errorf("Failed to process corporate action %d: %v", action.ID, err)
+		}
+	}
+
+	// Dividends go last so record-date holdings reflect any splits already applied
+	s.processDueDividends(ctx)
+}
+
+// Process writes a CORPORATE_ACTION ledger entry for every active reward in
+// the symbol granted before the ex-date that has not yet been adjusted.
+// It is safe to run more than once and returns the number of rewards adjusted.
+func (s *CorporateActionService) Process(ctx context.Context, actionID int64) (int, error) {
+	adjusted := 0
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var action models.CorporateAction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&action, actionID).Error; err != nil {
+			return err
+		}
+		if err := lockSymbol(tx, action.StockSymbol); err != nil {
+			return err
+		}
+
+		var rewards []models.StockReward
+		if err := tx.Where("stock_symbol = ? AND rewarded_at < ? AND reversed_at IS NULL", action.StockSymbol, ISTDayStart(action.ExDate)).
+			Where("id NOT IN (?)", tx.Model(&models.LedgerEntry{}).
+				Select("reward_id").
+				Where("corporate_action_id = ?", action.ID)).
+			Order("id").Find(&rewards).Error; err != nil {
+			return err
+		}
+
+		journal := models.Journal{
+			Reference:   fmt.Sprintf("corporate_action:%d", action.ID),
+			Description: fmt.Sprintf("%s %d:%d in %s", action.ActionType, action.RatioOld, action.RatioNew, action.StockSymbol),
+			PostedAt:    action.ExDate,
+		}
+
+		factor := action.QuantityFactor()
+		for _, reward := range rewards {
+			// Holdings before this action include adjustments from actions
+			// ordered before it by (ex_date, id), so actions on the same
+			// ex-date compound too
+			var prior decimal.Decimal
+			if err := tx.Model(&models.LedgerEntry{}).
+				Joins("JOIN corporate_actions ca ON ca.id = ledger_entries.corporate_action_id").
+				Where("ledger_entries.reward_id = ? AND (ca.ex_date < ? OR (ca.ex_date = ? AND ca.id < ?))",
+					reward.ID, action.ExDate, action.ExDate, action.ID).
+				Select("COALESCE(SUM(ledger_entries.quantity), 0)").
+				Row().Scan(&prior); err != nil {
+				return err
+			}
+
+			held := reward.Quantity.Add(prior)
+			delta := models.RoundQuantity(held.Mul(factor).Sub(held))
+			journal.Entries = append(journal.Entries, models.LedgerEntry{
+				AccountCode:       models.AccountUserStockHoldings,
+				RewardID:          &reward.ID,
+				UserID:            reward.UserID,
+				EntryType:         models.EntryTypeCorporateAction,
+				StockSymbol:       action.StockSymbol,
+				Quantity:          delta,
+				Amount:            decimal.Zero,
+				Description:       fmt.Sprintf("%s %d:%d ex %s", action.ActionType, action.RatioOld, action.RatioNew, action.ExDate.Format("2006-01-02")),
+				CorporateActionID: &action.ID,
+			})
+			adjusted++
+		}
+
+		// Quantity-only entries carry no amount, so the journal balances trivially
+		if len(journal.Entries) > 0 {
+			if err := s.ledger.Post(tx, &journal); err != nil {
+				return err
+			}
+		}
+
+		before := action
+		now := time.Now()
+		if err := tx.Model(&action).Update("processed_at", &now).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditCorporateActionProcess, "corporate_action", action.ID, before, action)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	logrus.Infof("Applied corporate action %d to %d rewards", actionID, adjusted)
+	return adjusted, nil
+}
+
+// checkBackdatedReward refuses a reward in symbol granted before the
+// ex-date of a corporate action, or on or before the record date of a
+// dividend, that has already been processed. Processing covers only the
+// rewards that exist at the time, so a reward backdated past it would never
+// be adjusted or paid. The symbol is locked until tx ends, so an action or
+// dividend processed concurrently, even one recorded after this check,
+// either finishes first and is seen here, or waits for this reward to commit
+// and includes it.
+func checkBackdatedReward(tx *gorm.DB, symbol string, rewardedAt time.Time) error {
+	rewardDate := rewardedAt.In(IST).Format("2006-01-02")
+	if err := lockSymbol(tx, symbol); err != nil {
+		return err
+	}
+
+	var actions []models.CorporateAction
+	if err := tx.Where("stock_symbol = ? AND ex_date > ?", symbol, rewardDate).
+		Order("ex_date DESC").Find(&actions).Error; err != nil {
+		return err
+	}
+	for _, action := range actions {
+		if action.ProcessedAt != nil {
+			return fmt.Errorf("%w: %s rewards can't be dated before %s, the ex-date of a %s already applied",
+				ErrInvalidReward, symbol, action.ExDate.Format("2006-01-02"), action.ActionType)
+		}
+	}
+
+	var dividends []models.Dividend
+	if err := tx.Where("stock_symbol = ? AND record_date >= ?", symbol, rewardDate).
+		Order("record_date DESC").Find(&dividends).Error; err != nil {
+		return err
+	}
+	for _, dividend := range dividends {
+		if dividend.ProcessedAt != nil {
+			return fmt.Errorf("%w: %s rewards can't be dated on or before %s, the record date of a dividend already paid",
+				ErrInvalidReward, symbol, dividend.RecordDate.Format("2006-01-02"))
+		}
+	}
+	return nil
+}
+
+// lockSymbol takes the symbol's advisory lock until tx ends, serialising
+// backdated rewards with corporate action and dividend processing
+func lockSymbol(tx *gorm.DB, symbol string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?::int, hashtext(?))", symbolLockSpace, symbol).Error
+}
+
+// GetAdjustments returns the live corporate-action adjustments for the given
+// rewards, keyed by reward ID and ordered by ex-date, then action
+func (s *CorporateActionService) GetAdjustments(rewardIDs []int64) (map[int64][]QuantityAdjustment, error) {
+	return getAdjustments(s.db, rewardIDs)
+}
+
+// getAdjustments is GetAdjustments read with db, which may be a transaction
+func getAdjustments(db *gorm.DB, rewardIDs []int64) (map[int64][]QuantityAdjustment, error) {
+	result := make(map[int64][]QuantityAdjustment)
+	if len(rewardIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []QuantityAdjustment
+	if err := db.Model(&models.LedgerEntry{}).
+		Joins("JOIN corporate_actions ca ON ca.id = ledger_entries.corporate_action_id").
+		Where("ledger_entries.reward_id IN ?", rewardIDs).
+		Select("ledger_entries.reward_id, ledger_entries.quantity, ca.ex_date").
+		Order("ca.ex_date, ca.id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		row.ExDate = ISTDayStart(row.ExDate)
+		result[row.RewardID] = append(result[row.RewardID], row)
+	}
+	return result, nil
+}
+
+// AdjustedQuantity returns a reward's quantity after applying adjustments with an ex-date before asOf
+func AdjustedQuantity(reward models.StockReward, adjustments []QuantityAdjustment, asOf time.Time) decimal.Decimal {
+	qty := reward.Quantity
+	for _, adj := range adjustments {
+		if adj.ExDate.Before(asOf) {
+			qty = qty.Add(adj.Quantity)
+		}
+	}
+	return qty
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"stocky/audit"
+	"stocky/models"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeclareDividend stores a dividend and pays it out immediately if its record date has passed
+func (s *CorporateActionService) DeclareDividend(ctx context.Context, dividend *models.Dividend) (int, error) {
+	if !dividend.AmountPerShare.IsPositive() {
+		return 0, fmt.Errorf("%w: amount per share must be positive", ErrInvalidCorporateAction)
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(dividend).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditDividendDeclare, "dividend", dividend.ID, nil, dividend)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to declare dividend: %w", err)
+	}
+
+	if dividend.RecordDate.After(time.Now()) {
+		return 0, nil
+	}
+	return s.ProcessDividend(ctx, dividend.ID)
+}
+
+func (s *CorporateActionService) processDueDividends(ctx context.Context) {
+	var dividends []models.Dividend
+	if err := s.db.WithContext(ctx).Where("processed_at IS NULL AND record_date <= ?", time.Now()).
+		Order("record_date, id").Find(&dividends).Error; err != nil {
+		logrus.Errorf("Failed to fetch due dividends: %v", err)
+		return
+	}
+
+	for _, dividend := range dividends {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, err := s.ProcessDividend(ctx, dividend.ID); err != nil {
+			logrus.Errorf("Failed to process dividend %d: %v", dividend.ID, err)
+		}
+	}
+}
+
+// ProcessDividend computes each user's holdings at the end of the record date
+// and posts a balanced journal per user crediting the dividend (less TDS where due).
+// Users already paid for this dividend are skipped. Returns the number of payouts made.
+func (s *CorporateActionService) ProcessDividend(ctx context.Context, dividendID int64) (int, error) {
+	payouts := 0
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var dividend models.Dividend
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&dividend, dividendID).Error; err != nil {
+			return err
+		}
+		if err := lockSymbol(tx, dividend.StockSymbol); err != nil {
+			return err
+		}
+
+		endOfRecordDate := ISTDayStart(dividend.RecordDate).AddDate(0, 0, 1)
+
+		var rewards []models.StockReward
+		if err := tx.Where("stock_symbol = ? AND rewarded_at < ? AND reversed_at IS NULL", dividend.StockSymbol, endOfRecordDate).
+			Where("user_id NOT IN (?)", tx.Model(&models.DividendPayout{}).
+				Select("user_id").
+				Where("dividend_id = ?", dividend.ID)).
+			Order("user_id, id").Find(&rewards).Error; err != nil {
+			return err
+		}
+
+		adjustments, err := getAdjustments(tx, RewardIDs(rewards))
+		if err != nil {
+			return err
+		}
+
+		holdings := make(map[string]decimal.Decimal)
+		var users []string
+		for _, reward := range rewards {
+			if _, seen := holdings[reward.UserID]; !seen {
+				users = append(users, reward.UserID)
+			}
+			holdings[reward.UserID] = holdings[reward.UserID].Add(AdjustedQuantity(reward, adjustments[reward.ID], endOfRecordDate))
+		}
+
+		for _, userID := range users {
+			qty := holdings[userID]
+			gross := models.RoundAmount(qty.Mul(dividend.AmountPerShare))
+			if !gross.IsPositive() {
+				continue
+			}
+
+			tds, err := s.dividendTDS(tx, userID, dividend, gross)
+			if err != nil {
+				return err
+			}
+
+			payout := models.DividendPayout{
+				DividendID:       dividend.ID,
+				UserID:           userID,
+				StockSymbol:      dividend.StockSymbol,
+				RecordDate:       dividend.RecordDate,
+				EligibleQuantity: qty,
+				AmountPerShare:   dividend.AmountPerShare,
+				GrossAmount:      gross,
+				TDSAmount:        tds,
+				NetAmount:        gross.Sub(tds),
+			}
+			if err := tx.Create(&payout).Error; err != nil {
+				return err
+			}
+
+			// The issuer pays the company as registered holder; the gross is owed
+			// to the user, less any TDS which is owed to the government
+			description := fmt.Sprintf("Dividend of %s/share on %s shares, record date %s", dividend.AmountPerShare, qty, dividend.RecordDate.Format("2006-01-02"))
+			journal := models.Journal{
+				Reference:   fmt.Sprintf("dividend:%d:%s", dividend.ID, userID),
+				Description: description,
+				Entries: []models.LedgerEntry{
+					{
+						AccountCode: models.AccountCompanyCash,
+						UserID:      userID,
+						EntryType:   models.EntryTypeDividendReceived,
+						StockSymbol: dividend.StockSymbol,
+						Quantity:    qty,
+						Amount:      gross,
+						Description: description,
+						DividendID:  &dividend.ID,
+					},
+					{
+						AccountCode: models.AccountUserDividendPayable,
+						UserID:      userID,
+						EntryType:   models.EntryTypeDividendCredit,
+						StockSymbol: dividend.StockSymbol,
+						Quantity:    qty,
+						Amount:      gross.Neg(),
+						Description: description,
+						DividendID:  &dividend.ID,
+					},
+				},
+			}
+			if tds.IsPositive() {
+				tdsDescription := fmt.Sprintf("TDS at %s%% on dividend", s.cfg.DividendTDSRate.Shift(2))
+				journal.Entries = append(journal.Entries,
+					models.LedgerEntry{
+						AccountCode: models.AccountUserDividendPayable,
+						UserID:      userID,
+						EntryType:   models.EntryTypeTDSDebit,
+						StockSymbol: dividend.StockSymbol,
+						Amount:      tds,
+						Description: tdsDescription,
+						DividendID:  &dividend.ID,
+					},
+					models.LedgerEntry{
+						AccountCode: models.AccountTDSPayable,
+						UserID:      userID,
+						EntryType:   models.EntryTypeTDSWithheld,
+						StockSymbol: dividend.StockSymbol,
+						Amount:      tds.Neg(),
+						Description: tdsDescription,
+						DividendID:  &dividend.ID,
+					},
+				)
+			}
+			if err := s.ledger.Post(tx, &journal); err != nil {
+				return err
+			}
+			payouts++
+		}
+
+		before := dividend
+		now := time.Now()
+		if err := tx.Model(&dividend).Update("processed_at", &now).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditDividendProcess, "dividend", dividend.ID, before, dividend)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	logrus.Infof("Paid dividend %d to %d users", dividendID, payouts)
+	return payouts, nil
+}
+
+// dividendTDS returns the tax to withhold on a payout. TDS applies once the
+// user's dividends from the company in the financial year (April-March)
+// exceed the configured threshold.
+func (s *CorporateActionService) dividendTDS(tx *gorm.DB, userID string, dividend models.Dividend, gross decimal.Decimal) (decimal.Decimal, error) {
+	if !s.cfg.DividendTDSRate.IsPositive() {
+		return decimal.Zero, nil
+	}
+
+	rd := dividend.RecordDate.In(IST)
+	fyStartYear := rd.Year()
+	if rd.Month() < time.April {
+		fyStartYear--
+	}
+	fyStart := time.Date(fyStartYear, time.April, 1, 0, 0, 0, 0, IST)
+
+	var paid decimal.Decimal
+	if err := tx.Model(&models.DividendPayout{}).
+		Where("user_id = ? AND stock_symbol = ? AND record_date >= ? AND record_date <= ?",
+			userID, dividend.StockSymbol, fyStart, dividend.RecordDate).
+		Select("COALESCE(SUM(gross_amount), 0)").
+		Row().Scan(&paid); err != nil {
+		return decimal.Zero, err
+	}
+
+	if paid.Add(gross).LessThanOrEqual(s.cfg.DividendTDSThreshold) {
+		return decimal.Zero, nil
+	}
+	return models.RoundAmount(gross.Mul(s.cfg.DividendTDSRate)), nil
+}
+
+// ListDividends returns declared dividends, newest record date first, optionally for one symbol
+func (s *CorporateActionService) ListDividends(symbol string) ([]models.Dividend, error) {
+	query := s.db.Order("record_date DESC, id DESC")
+	if symbol != "" {
+		query = query.Where("stock_symbol = ?", symbol)
+	}
+
+	var dividends []models.Dividend
+	if err := query.Find(&dividends).Error; err != nil {
+		return nil, err
+	}
+	return dividends, nil
+}
+
+// GetUserDividends returns a user's dividend payouts, newest first
+func (s *CorporateActionService) GetUserDividends(userID string) ([]models.DividendPayout, error) {
+	var payouts []models.DividendPayout
+	if err := s.db.Where("user_id = ?", userID).
+		Order("record_date DESC, id DESC").
+		Find(&payouts).Error; err != nil {
+		return nil, err
+	}
+	return payouts, nil
+}
+
+// RewardIDs returns the IDs of the given rewards
+func RewardIDs(rewards []models.StockReward) []int64 {
+	ids := make([]int64, len(rewards))
+	for i, reward := range rewards {
+		ids[i] = reward.ID
+	}
+	return ids
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stocky/audit"
+	"stocky/models"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidFeeSchedule = errors.New("invalid fee schedule")
+	ErrNoFeeSchedule      = errors.New("no fee schedule in effect")
+)
+
+type FeeService struct {
+	db *gorm.DB
+}
+
+func NewFeeService(db *gorm.DB) *FeeService {
+	return &FeeService{db: db}
+}
+
+// DefaultFeeSchedule is the hypothetical schedule the service started with:
+// 0.5% brokerage + 0.1% STT + 18% GST on brokerage
+func DefaultFeeSchedule() models.FeeSchedule {
+	return models.FeeSchedule{
+		Name:          "Default",
+		EffectiveFrom: time.Date(2000, time.January, 1, 0, 0, 0, 0, time.Local),
+		Components: []models.FeeComponent{
+			{Sequence: 1, Code: "BROKERAGE", Name: "Brokerage", Rate: decimal.RequireFromString("0.005"), Basis: models.FeeBasisTradeValue},
+			{Sequence: 2, Code: "STT", Name: "Securities Transaction Tax", Rate: decimal.RequireFromString("0.001"), Basis: models.FeeBasisTradeValue},
+			{Sequence: 3, Code: models.FeeCodeGST, Name: "GST", Rate: decimal.RequireFromString("0.18"), Basis: "BROKERAGE"},
+		},
+	}
+}
+
+// EnsureDefaultSchedule seeds DefaultFeeSchedule when no schedule exists
+func (s *FeeService) EnsureDefaultSchedule() error {
+	var count int64
+	if err := s.db.Model(&models.FeeSchedule{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	schedule := DefaultFeeSchedule()
+	if err := s.CreateSchedule(context.Background(), &schedule); err != nil {
+		return err
+	}
+	logrus.Info("Seeded default fee schedule")
+	return nil
+}
+
+// CreateSchedule validates and stores a new fee schedule version
+func (s *FeeService) CreateSchedule(ctx context.Context, schedule *models.FeeSchedule) error {
+	if err := validateFeeSchedule(schedule); err != nil {
+		return err
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(schedule).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditFeeScheduleCreate, "fee_schedule", schedule.ID, nil, schedule)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create fee schedule: %w", err)
+	}
+	return nil
+}
+
+func validateFeeSchedule(schedule *models.FeeSchedule) error {
+	if len(schedule.Components) == 0 {
+		return fmt.Errorf("%w: at least one component is required", ErrInvalidFeeSchedule)
+	}
+
+	seen := make(map[string]bool)
+	for i := range schedule.Components {
+		component := &schedule.Components[i]
+		component.Code = strings.ToUpper(component.Code)
+		component.Sequence = i + 1
+
+		if seen[component.Code] {
+			return fmt.Errorf("%w: duplicate component %s", ErrInvalidFeeSchedule, component.Code)
+		}
+		if component.Rate.IsNegative() {
+			return fmt.Errorf("%w: %s rate must not be negative", ErrInvalidFeeSchedule, component.Code)
+		}
+		if component.Basis != models.FeeBasisTradeValue {
+			codes := feeBasisCodes(component.Basis)
+			if len(codes) == 0 {
+				return fmt.Errorf("%w: %s needs a basis, %s or earlier component codes", ErrInvalidFeeSchedule, component.Code, models.FeeBasisTradeValue)
+			}
+			for _, code := range codes {
+				if !seen[code] {
+					return fmt.Errorf("%w: %s is charged on %s, which must be an earlier component", ErrInvalidFeeSchedule, component.Code, code)
+				}
+			}
+		}
+		seen[component.Code] = true
+	}
+	return nil
+}
+
+// ListSchedules returns all fee schedule versions, newest first
+func (s *FeeService) ListSchedules() ([]models.FeeSchedule, error) {
+	var schedules []models.FeeSchedule
+	if err := s.db.Preload("Components", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sequence")
+	}).Order("effective_from DESC").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// GetSchedule returns the fee schedule in effect at the given time
+func (s *FeeService) GetSchedule(at time.Time) (*models.FeeSchedule, error) {
+	var schedule models.FeeSchedule
+	err := s.db.Preload("Components", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sequence")
+	}).Where("effective_from <= ?", at).Order("effective_from DESC").First(&schedule).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrNoFeeSchedule
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// Calculate returns the itemised fees for buying stock worth tradeValue at the given time
+func (s *FeeService) Calculate(at time.Time, tradeValue decimal.Decimal) (*models.FeeBreakdown, error) {
+	schedule, err := s.GetSchedule(at)
+	if err != nil {
+		return nil, err
+	}
+	return CalculateFees(schedule, tradeValue), nil
+}
+
+// CalculateFees applies a schedule to a trade value. Each component is
+// rounded to paise so the items add up exactly to the total.
+func CalculateFees(schedule *models.FeeSchedule, tradeValue decimal.Decimal) *models.FeeBreakdown {
+	breakdown := &models.FeeBreakdown{ScheduleID: schedule.ID, Total: decimal.Zero}
+	amounts := make(map[string]decimal.Decimal)
+
+	for _, component := range schedule.Components {
+		base := tradeValue
+		if component.Basis != models.FeeBasisTradeValue {
+			base = decimal.Zero
+			for _, code := range feeBasisCodes(component.Basis) {
+				base = base.Add(amounts[code])
+			}
+		}
+
+		amount := base.Mul(component.Rate)
+		if component.MinAmount.Valid && amount.LessThan(component.MinAmount.Decimal) {
+			amount = component.MinAmount.Decimal
+		}
+		if component.MaxAmount.Valid && amount.GreaterThan(component.MaxAmount.Decimal) {
+			amount = component.MaxAmount.Decimal
+		}
+		amount = models.RoundAmount(amount)
+
+		amounts[component.Code] = amount
+		breakdown.Items = append(breakdown.Items, models.FeeItem{
+			Code:   component.Code,
+			Name:   component.Name,
+			Amount: amount,
+		})
+		breakdown.Total = breakdown.Total.Add(amount)
+	}
+	return breakdown
+}
+
+func feeBasisCodes(basis string) []string {
+	var codes []string
+	for _, code := range strings.Split(basis, ",") {
+		if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
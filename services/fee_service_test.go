@@ -0,0 +1,132 @@
+package services
+
+import (
+	"errors"
+	"stocky/models"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCalculateFees(t *testing.T) {
+	d := decimal.RequireFromString
+	defaultSchedule := DefaultFeeSchedule()
+
+	// An NSE-style schedule: capped brokerage, exchange and SEBI charges,
+	// stamp duty, and GST on brokerage and the exchange and SEBI charges
+	nse := models.FeeSchedule{
+		Components: []models.FeeComponent{
+			{Sequence: 1, Code: "BROKERAGE", Rate: d("0.005"), Basis: models.FeeBasisTradeValue, MinAmount: decimal.NewNullDecimal(d("5")), MaxAmount: decimal.NewNullDecimal(d("20"))},
+			{Sequence: 2, Code: "EXCHANGE", Rate: d("0.0000345"), Basis: models.FeeBasisTradeValue},
+			{Sequence: 3, Code: "SEBI", Rate: d("0.000001"), Basis: models.FeeBasisTradeValue},
+			{Sequence: 4, Code: "STAMP", Rate: d("0.00015"), Basis: models.FeeBasisTradeValue},
+			{Sequence: 5, Code: models.FeeCodeGST, Rate: d("0.18"), Basis: "brokerage, EXCHANGE,SEBI"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		schedule   *models.FeeSchedule
+		tradeValue string
+		want       map[string]string
+		wantTotal  string
+	}{
+		{
+			name: "default schedule", schedule: &defaultSchedule, tradeValue: "10000",
+			want:      map[string]string{"BROKERAGE": "50", "STT": "10", "GST": "9"},
+			wantTotal: "69",
+		},
+		{
+			name: "each component rounded to paise before GST", schedule: &defaultSchedule, tradeValue: "2452.40",
+			// brokerage 12.262, STT 2.4524, GST 18% of the rounded 12.26 = 2.2068
+			want:      map[string]string{"BROKERAGE": "12.26", "STT": "2.45", "GST": "2.21"},
+			wantTotal: "16.92",
+		},
+		{
+			name: "half a paisa rounds to even", schedule: &defaultSchedule, tradeValue: "1",
+			// brokerage 0.005, STT 0.001
+			want:      map[string]string{"BROKERAGE": "0", "STT": "0", "GST": "0"},
+			wantTotal: "0",
+		},
+		{
+			name: "half a paisa rounds up to even", schedule: &defaultSchedule, tradeValue: "3",
+			// brokerage 0.015, STT 0.003, GST 18% of 0.02 = 0.0036
+			want:      map[string]string{"BROKERAGE": "0.02", "STT": "0", "GST": "0"},
+			wantTotal: "0.02",
+		},
+		{
+			name: "brokerage capped", schedule: &nse, tradeValue: "100000",
+			// GST 18% of 20 + 3.45 + 0.10 = 4.239
+			want:      map[string]string{"BROKERAGE": "20", "EXCHANGE": "3.45", "SEBI": "0.1", "STAMP": "15", "GST": "4.24"},
+			wantTotal: "42.79",
+		},
+		{
+			name: "brokerage minimum", schedule: &nse, tradeValue: "100",
+			// exchange 0.00345, SEBI 0.0001, stamp 0.015, GST 18% of 5 = 0.9
+			want:      map[string]string{"BROKERAGE": "5", "EXCHANGE": "0", "SEBI": "0", "STAMP": "0.02", "GST": "0.9"},
+			wantTotal: "5.92",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breakdown := CalculateFees(tt.schedule, d(tt.tradeValue))
+
+			if len(breakdown.Items) != len(tt.want) {
+				t.Fatalf("got %d fee items, want %d", len(breakdown.Items), len(tt.want))
+			}
+			sum := decimal.Zero
+			for _, item := range breakdown.Items {
+				want, ok := tt.want[item.Code]
+				if !ok {
+					t.Fatalf("unexpected fee item %s", item.Code)
+				}
+				if !item.Amount.Equal(d(want)) {
+					t.Errorf("%s = %s, want %s", item.Code, item.Amount, want)
+				}
+				sum = sum.Add(item.Amount)
+			}
+			if !breakdown.Total.Equal(d(tt.wantTotal)) {
+				t.Errorf("total = %s, want %s", breakdown.Total, tt.wantTotal)
+			}
+			if !breakdown.Total.Equal(sum) {
+				t.Errorf("total %s doesn't equal the sum of items %s", breakdown.Total, sum)
+			}
+		})
+	}
+}
+
+func TestValidateFeeSchedule(t *testing.T) {
+	component := func(code, basis string) models.FeeComponent {
+		return models.FeeComponent{Code: code, Rate: decimal.RequireFromString("0.01"), Basis: basis}
+	}
+
+	tests := []struct {
+		name       string
+		components []models.FeeComponent
+		wantErr    bool
+	}{
+		{"trade value and earlier components", []models.FeeComponent{
+			component("BROKERAGE", models.FeeBasisTradeValue), component("SEBI", models.FeeBasisTradeValue), component("GST", "brokerage, SEBI"),
+		}, false},
+		{"no components", nil, true},
+		{"empty basis", []models.FeeComponent{component("BROKERAGE", "")}, true},
+		{"basis of separators only", []models.FeeComponent{component("BROKERAGE", models.FeeBasisTradeValue), component("GST", " , ")}, true},
+		{"basis names a later component", []models.FeeComponent{
+			component("GST", "BROKERAGE"), component("BROKERAGE", models.FeeBasisTradeValue),
+		}, true},
+		{"basis names an unknown component", []models.FeeComponent{component("BROKERAGE", models.FeeBasisTradeValue), component("GST", "BROKERAGE,STT")}, true},
+		{"basis names itself", []models.FeeComponent{component("GST", "GST")}, true},
+		{"duplicate code", []models.FeeComponent{component("STT", models.FeeBasisTradeValue), component("stt", models.FeeBasisTradeValue)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFeeSchedule(&models.FeeSchedule{Components: tt.components})
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("validateFeeSchedule() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidFeeSchedule) {
+				t.Fatalf("validateFeeSchedule() error = %v, want ErrInvalidFeeSchedule", err)
+			}
+		})
+	}
+}
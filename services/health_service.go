@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stocky/database"
+	"stocky/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HealthService reports whether the service and the things it depends on
+// can serve traffic
+type HealthService struct {
+	db     *gorm.DB
+	prices *StockPriceService
+}
+
+func NewHealthService(db *gorm.DB, prices *StockPriceService) *HealthService {
+	return &HealthService{db: db, prices: prices}
+}
+
+// Readiness checks the database connection, that migrations have been
+// applied, and that the price updater has succeeded within two intervals
+func (s *HealthService) Readiness(ctx context.Context) *models.ReadinessResponse {
+	result := &models.ReadinessResponse{
+		Status: models.HealthStatusOK,
+		Checks: map[string]models.HealthCheck{
+			"database":      s.checkDatabase(ctx),
+			"migrations":    s.checkMigrations(ctx),
+			"price_updater": s.checkPriceUpdater(time.Now()),
+		},
+	}
+	for _, check := range result.Checks {
+		if check.Status != models.HealthStatusOK {
+			result.Status = models.HealthStatusFail
+		}
+	}
+	return result
+}
+
+func (s *HealthService) checkDatabase(ctx context.Context) models.HealthCheck {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return failedCheck(err, nil)
+	}
+
+	start := time.Now()
+	err = sqlDB.PingContext(ctx)
+	stats := sqlDB.Stats()
+	detail := map[string]interface{}{
+		"latency_ms":       time.Since(start).Milliseconds(),
+		"open_connections": stats.OpenConnections,
+		"in_use":           stats.InUse,
+	}
+	if err != nil {
+		return failedCheck(err, detail)
+	}
+	return models.HealthCheck{Status: models.HealthStatusOK, Detail: detail}
+}
+
+func (s *HealthService) checkMigrations(ctx context.Context) models.HealthCheck {
+	pending, err := database.PendingMigrations(s.db.WithContext(ctx))
+	if err != nil {
+		return failedCheck(err, nil)
+	}
+	if len(pending) > 0 {
+		return failedCheck(fmt.Errorf("schema is missing %s", strings.Join(pending, ", ")), map[string]interface{}{"pending": pending})
+	}
+	return models.HealthCheck{Status: models.HealthStatusOK}
+}
+
+func (s *HealthService) checkPriceUpdater(now time.Time) models.HealthCheck {
+	status := s.prices.UpdaterStatus()
+	switch {
+	case status.LastSuccessAt == nil && status.LastRunAt == nil:
+		return failedCheck(errors.New("no price update has run yet"), status)
+	case status.LastSuccessAt == nil:
+		return failedCheck(errors.New("no price update has succeeded yet"), status)
+	case now.Sub(*status.LastSuccessAt) > 2*PriceUpdateInterval:
+		return failedCheck(fmt.Errorf("last successful price update was %s ago", now.Sub(*status.LastSuccessAt).Round(time.Second)), status)
+	}
+	return models.HealthCheck{Status: models.HealthStatusOK, Detail: status}
+}
+
+func failedCheck(err error, detail interface{}) models.HealthCheck {
+	return models.HealthCheck{Status: models.HealthStatusFail, Error: err.Error(), Detail: detail}
+}
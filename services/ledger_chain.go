@@ -0,0 +1,485 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"stocky/audit"
+	"stocky/models"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var ErrLedgerChainBroken = errors.New("ledger hash chain is broken")
+
+// ledgerChainLock is the advisory lock key that serialises appends to the
+// hash chain, so entries are chained in the order of their IDs
+const ledgerChainLock = 0x4c454447 // "LEDG"
+
+// ledgerChainBatch is how many entries are read at a time when walking the chain
+const ledgerChainBatch = 1000
+
+// chainedContent is the part of a ledger entry covered by its hash. Fields
+// are listed explicitly so adding a column doesn't change existing hashes.
+type chainedContent struct {
+	ID                int64               `json:"id"`
+	JournalID         *int64              `json:"journal_id"`
+	AccountCode       string              `json:"account_code"`
+	RewardID          *int64              `json:"reward_id"`
+	UserID            string              `json:"user_id"`
+	EntryType         string              `json:"entry_type"`
+	StockSymbol       string              `json:"stock_symbol"`
+	FeeCode           string              `json:"fee_code"`
+	Quantity          decimal.Decimal     `json:"quantity"`
+	Amount            decimal.Decimal     `json:"amount"`
+	Price             decimal.NullDecimal `json:"price"`
+	Residue           decimal.NullDecimal `json:"residue"`
+	Description       string              `json:"description"`
+	ReversesID        *int64              `json:"reverses_id"`
+	CorporateActionID *int64              `json:"corporate_action_id"`
+	DividendID        *int64              `json:"dividend_id"`
+	CreatedAt         string              `json:"created_at"`
+}
+
+// EntryHash returns the chain hash of a ledger entry as stored in the
+// database, given the hash of the entry before it ("" for the first)
+func EntryHash(prevHash string, entry models.LedgerEntry) string {
+	content, err := json.Marshal(chainedContent{
+		ID:                entry.ID,
+		JournalID:         entry.JournalID,
+		AccountCode:       entry.AccountCode,
+		RewardID:          entry.RewardID,
+		UserID:            entry.UserID,
+		EntryType:         entry.EntryType,
+		StockSymbol:       entry.StockSymbol,
+		FeeCode:           entry.FeeCode,
+		Quantity:          entry.Quantity,
+		Amount:            entry.Amount,
+		Price:             entry.Price,
+		Residue:           entry.Residue,
+		Description:       entry.Description,
+		ReversesID:        entry.ReversesID,
+		CorporateActionID: entry.CorporateActionID,
+		DividendID:        entry.DividendID,
+		CreatedAt:         entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		// Every field is a plain value or decimal, which always encode
+		panic(err)
+	}
+
+	sum := sha256.New()
+	sum.Write([]byte(prevHash))
+	sum.Write([]byte{'\n'})
+	sum.Write(content)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// lockChain takes the chain lock until tx ends
+func lockChain(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", ledgerChainLock).Error
+}
+
+// chainHead returns the ID and hash of the last chained entry
+func chainHead(tx *gorm.DB) (int64, string, error) {
+	var head models.LedgerEntry
+	err := tx.Select("id", "hash").Where("hash <> ''").Order("id DESC").Take(&head).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	return head.ID, head.Hash, nil
+}
+
+// chainEntries hashes entries onto the chain after prevHash, in order, and
+// returns the new head hash. Entries are hashed as read back from the
+// database so rounding and timestamp precision match what verification sees.
+func chainEntries(tx *gorm.DB, prevHash string, entries []models.LedgerEntry) (string, error) {
+	for _, entry := range entries {
+		hash := EntryHash(prevHash, entry)
+		if err := tx.Model(&models.LedgerEntry{}).Where("id = ?", entry.ID).
+			Updates(map[string]interface{}{"prev_hash": prevHash, "hash": hash}).Error; err != nil {
+			return "", err
+		}
+		prevHash = hash
+	}
+	return prevHash, nil
+}
+
+// chainJournal appends a just-posted journal's entries to the chain. tx
+// must hold the chain lock.
+func chainJournal(tx *gorm.DB, journalID int64) error {
+	_, head, err := chainHead(tx)
+	if err != nil {
+		return err
+	}
+
+	var entries []models.LedgerEntry
+	if err := tx.Where("journal_id = ?", journalID).Order("id").Find(&entries).Error; err != nil {
+		return err
+	}
+	_, err = chainEntries(tx, head, entries)
+	return err
+}
+
+// BackfillHashChain chains entries written before the hash chain existed.
+// Only unchained entries after the current head are added; anything older
+// was inserted around the chain and is left for verification to report.
+func (s *LedgerService) BackfillHashChain() error {
+	chained := 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockChain(tx); err != nil {
+			return err
+		}
+		headID, head, err := chainHead(tx)
+		if err != nil {
+			return err
+		}
+
+		for {
+			var entries []models.LedgerEntry
+			if err := tx.Where("id > ? AND hash = ''", headID).
+				Order("id").Limit(ledgerChainBatch).Find(&entries).Error; err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				return nil
+			}
+			if head, err = chainEntries(tx, head, entries); err != nil {
+				return err
+			}
+			headID = entries[len(entries)-1].ID
+			chained += len(entries)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to backfill ledger hash chain: %w", err)
+	}
+
+	if chained > 0 {
+		logrus.Infof("Added %d existing ledger entries to the hash chain", chained)
+	}
+	return nil
+}
+
+// chainWalk follows the chain one entry at a time, checking each against
+// the entry before it and against any checkpoint taken at it
+type chainWalk struct {
+	checkpoints map[int64][]models.LedgerCheckpoint // by LastEntryID
+	entries     int64
+	lastID      int64
+	lastHash    string
+	seen        map[int64]bool // checkpointed entries reached, to spot missing ones
+	checked     int            // checkpoints compared
+	firstBad    *models.LedgerChainBreak
+}
+
+// newChainWalk starts a walk after entry afterID, whose hash is prevHash
+func newChainWalk(afterID int64, prevHash string, checkpoints []models.LedgerCheckpoint) *chainWalk {
+	walk := &chainWalk{
+		checkpoints: make(map[int64][]models.LedgerCheckpoint),
+		lastID:      afterID,
+		lastHash:    prevHash,
+		seen:        make(map[int64]bool),
+	}
+	for _, cp := range checkpoints {
+		walk.checkpoints[cp.LastEntryID] = append(walk.checkpoints[cp.LastEntryID], cp)
+	}
+	return walk
+}
+
+// next checks that entry links to the one before it, that its hash matches
+// its content and that it matches any checkpoint taken at it. It records
+// the break and returns false if not.
+func (w *chainWalk) next(entry models.LedgerEntry) bool {
+	if entry.PrevHash != w.lastHash {
+		w.firstBad = &models.LedgerChainBreak{
+			EntryID:  entry.ID,
+			Reason:   "prev_hash does not match the previous entry's hash; an entry was removed or inserted",
+			Expected: w.lastHash,
+			Found:    entry.PrevHash,
+		}
+		return false
+	}
+	if hash := EntryHash(entry.PrevHash, entry); hash != entry.Hash {
+		reason := "hash does not match the entry's content"
+		if entry.Hash == "" {
+			reason = "entry is not on the hash chain"
+		}
+		w.firstBad = &models.LedgerChainBreak{EntryID: entry.ID, Reason: reason, Expected: hash, Found: entry.Hash}
+		return false
+	}
+	for _, cp := range w.checkpoints[entry.ID] {
+		w.checked++
+		if cp.Hash != entry.Hash {
+			w.firstBad = &models.LedgerChainBreak{
+				EntryID:      entry.ID,
+				CheckpointID: cp.ID,
+				Reason:       "hash differs from a checkpoint; the chain was rewritten from here or earlier",
+				Expected:     cp.Hash,
+				Found:        entry.Hash,
+			}
+			return false
+		}
+		w.seen[entry.ID] = true
+	}
+	w.entries++
+	w.lastID = entry.ID
+	w.lastHash = entry.Hash
+	return true
+}
+
+// missingCheckpoint returns a break for the first checkpoint whose entry the
+// walk never reached, which means the newest entries were deleted
+func (w *chainWalk) missingCheckpoint(checkpoints []models.LedgerCheckpoint) *models.LedgerChainBreak {
+	for _, cp := range checkpoints {
+		if !w.seen[cp.LastEntryID] {
+			return &models.LedgerChainBreak{
+				EntryID:      cp.LastEntryID,
+				CheckpointID: cp.ID,
+				Reason:       "checkpointed entry is missing; the ledger was truncated",
+				Expected:     cp.Hash,
+			}
+		}
+	}
+	return nil
+}
+
+// checkAnchors compares the checkpoints in the database with their copies
+// in the anchor file and returns a break for the first that was deleted or
+// altered
+func checkAnchors(checkpoints, anchors []models.LedgerCheckpoint) *models.LedgerChainBreak {
+	stored := make(map[int64]models.LedgerCheckpoint, len(checkpoints))
+	for _, cp := range checkpoints {
+		stored[cp.ID] = cp
+	}
+	for _, anchor := range anchors {
+		cp, ok := stored[anchor.ID]
+		if !ok {
+			return &models.LedgerChainBreak{
+				EntryID:      anchor.LastEntryID,
+				CheckpointID: anchor.ID,
+				Reason:       "anchored checkpoint is missing from the database",
+				Expected:     anchor.Hash,
+			}
+		}
+		if cp.LastEntryID != anchor.LastEntryID || cp.Hash != anchor.Hash {
+			return &models.LedgerChainBreak{
+				EntryID:      anchor.LastEntryID,
+				CheckpointID: anchor.ID,
+				Reason:       "checkpoint differs from its anchored copy",
+				Expected:     anchor.Hash,
+				Found:        cp.Hash,
+			}
+		}
+	}
+	return nil
+}
+
+// walkChain checks the entries after afterID, starting from prevHash,
+// against each other and the given checkpoints. It stops at the first break.
+func (s *LedgerService) walkChain(ctx context.Context, afterID int64, prevHash string, checkpoints []models.LedgerCheckpoint) (*chainWalk, error) {
+	walk := newChainWalk(afterID, prevHash, checkpoints)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var entries []models.LedgerEntry
+		if err := s.db.WithContext(ctx).Where("id > ?", walk.lastID).
+			Order("id").Limit(ledgerChainBatch).Find(&entries).Error; err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			return walk, nil
+		}
+
+		for _, entry := range entries {
+			if !walk.next(entry) {
+				return walk, nil
+			}
+		}
+	}
+}
+
+// VerifyChain walks the whole ledger hash chain and reports the first entry
+// that was edited, removed or inserted outside the chain. Entries are also
+// checked against every checkpoint, which catches a chain rewritten or
+// truncated wholesale, and the checkpoints against their copies in the
+// anchor file, which catches checkpoints rewritten along with it.
+func (s *LedgerService) VerifyChain(ctx context.Context) (*models.LedgerVerification, error) {
+	var checkpoints []models.LedgerCheckpoint
+	if err := s.db.WithContext(ctx).Order("id").Find(&checkpoints).Error; err != nil {
+		return nil, err
+	}
+	anchors, err := s.readAnchors()
+	if err != nil {
+		return nil, err
+	}
+
+	walk, err := s.walkChain(ctx, 0, "", checkpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.LedgerVerification{
+		Status:             models.LedgerChainOK,
+		EntriesChecked:     walk.entries,
+		LastEntryID:        walk.lastID,
+		LastHash:           walk.lastHash,
+		CheckpointsChecked: walk.checked,
+		FirstBreak:         walk.firstBad,
+		VerifiedAt:         time.Now(),
+	}
+	if result.FirstBreak == nil {
+		result.FirstBreak = walk.missingCheckpoint(checkpoints)
+	}
+	if result.FirstBreak == nil {
+		result.FirstBreak = checkAnchors(checkpoints, anchors)
+	}
+	if result.FirstBreak != nil {
+		result.Status = models.LedgerChainBroken
+	}
+	return result, nil
+}
+
+// StartCheckpointer records a checkpoint every interval until ctx is
+// cancelled. An interval that isn't positive disables checkpointing.
+func (s *LedgerService) StartCheckpointer(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	logrus.Infof("Starting ledger checkpointer (runs every %s)", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Checkpoint(ctx); err != nil {
+				logrus.Errorf("Failed to checkpoint ledger: %v", err)
+			}
+		}
+	}
+}
+
+// Checkpoint verifies the entries added since the last checkpoint and, if
+// the chain is intact, records and anchors a checkpoint at its head. It
+// returns nil when nothing has been posted since the last checkpoint.
+func (s *LedgerService) Checkpoint(ctx context.Context) (*models.LedgerCheckpoint, error) {
+	var last models.LedgerCheckpoint
+	err := s.db.WithContext(ctx).Order("id DESC").Take(&last).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	walk, err := s.walkChain(ctx, last.LastEntryID, last.Hash, nil)
+	if err != nil {
+		return nil, err
+	}
+	if walk.firstBad != nil {
+		return nil, fmt.Errorf("%w at entry %d: %s", ErrLedgerChainBroken, walk.firstBad.EntryID, walk.firstBad.Reason)
+	}
+	if walk.entries == 0 {
+		return nil, nil
+	}
+
+	checkpoint := models.LedgerCheckpoint{
+		LastEntryID: walk.lastID,
+		Hash:        walk.lastHash,
+		EntryCount:  last.EntryCount + walk.entries,
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&checkpoint).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(tx, models.AuditLedgerCheckpoint, "ledger_checkpoint", checkpoint.ID, nil, checkpoint); err != nil {
+			return err
+		}
+		// Anchor before committing, so every stored checkpoint is anchored
+		return s.anchor(checkpoint)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record ledger checkpoint: %w", err)
+	}
+	return &checkpoint, nil
+}
+
+// ListCheckpoints returns ledger checkpoints, newest first
+func (s *LedgerService) ListCheckpoints() ([]models.LedgerCheckpoint, error) {
+	var checkpoints []models.LedgerCheckpoint
+	if err := s.db.Order("id DESC").Find(&checkpoints).Error; err != nil {
+		return nil, err
+	}
+	return checkpoints, nil
+}
+
+// anchor publishes a checkpoint outside the database: to the log, and as a
+// JSON line appended to the anchor file when one is configured
+func (s *LedgerService) anchor(checkpoint models.LedgerCheckpoint) error {
+	logrus.WithFields(logrus.Fields{
+		"checkpoint_id": checkpoint.ID,
+		"last_entry_id": checkpoint.LastEntryID,
+		"hash":          checkpoint.Hash,
+		"entry_count":   checkpoint.EntryCount,
+	}).Info("Ledger checkpoint")
+
+	if s.anchorFile == "" {
+		return nil
+	}
+	line, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.anchorFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open anchor file: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write anchor file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync anchor file: %w", err)
+	}
+	return f.Close()
+}
+
+// readAnchors loads the checkpoints in the anchor file, if there is one
+func (s *LedgerService) readAnchors() ([]models.LedgerCheckpoint, error) {
+	if s.anchorFile == "" {
+		return nil, nil
+	}
+	f, err := os.Open(s.anchorFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open anchor file: %w", err)
+	}
+	defer f.Close()
+
+	var anchors []models.LedgerCheckpoint
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var cp models.LedgerCheckpoint
+		if err := dec.Decode(&cp); err != nil {
+			return nil, fmt.Errorf("failed to read anchor file: %w", err)
+		}
+		anchors = append(anchors, cp)
+	}
+	return anchors, nil
+}
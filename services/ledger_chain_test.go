@@ -0,0 +1,266 @@
+package services
+
+import (
+	"stocky/models"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func int64Ptr(v int64) *int64 { return &v }
+
+func sampleEntry() models.LedgerEntry {
+	return models.LedgerEntry{
+		ID:          7,
+		JournalID:   int64Ptr(3),
+		AccountCode: models.AccountUserStockHoldings,
+		RewardID:    int64Ptr(5),
+		UserID:      "user123",
+		EntryType:   models.EntryTypeStockCredit,
+		StockSymbol: "RELIANCE",
+		Quantity:    decimal.RequireFromString("10.5"),
+		Amount:      decimal.RequireFromString("25750.25"),
+		Price:       decimal.NewNullDecimal(decimal.RequireFromString("2452.40")),
+		Description: "Stock reward credited to user user123",
+		CreatedAt:   time.Date(2025, 11, 10, 10, 15, 0, 123456000, IST),
+	}
+}
+
+func TestEntryHashStable(t *testing.T) {
+	base := sampleEntry()
+	baseHash := EntryHash("prev", base)
+
+	tests := []struct {
+		name   string
+		change func(*models.LedgerEntry)
+	}{
+		{"trailing zeros as read from numeric columns", func(e *models.LedgerEntry) {
+			e.Quantity = decimal.RequireFromString("10.500000")
+			e.Amount = decimal.RequireFromString("25750.2500")
+			e.Price = decimal.NewNullDecimal(decimal.RequireFromString("2452.4000"))
+		}},
+		{"same instant in another zone", func(e *models.LedgerEntry) {
+			e.CreatedAt = e.CreatedAt.UTC()
+		}},
+		{"equal pointers at a different address", func(e *models.LedgerEntry) {
+			e.JournalID = int64Ptr(3)
+			e.RewardID = int64Ptr(5)
+		}},
+		{"hash fields are not part of the content", func(e *models.LedgerEntry) {
+			e.PrevHash = "ignored"
+			e.Hash = "ignored"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := sampleEntry()
+			tt.change(&entry)
+			if got := EntryHash("prev", entry); got != baseHash {
+				t.Fatalf("EntryHash() = %s, want %s", got, baseHash)
+			}
+		})
+	}
+}
+
+func TestEntryHashChanges(t *testing.T) {
+	base := sampleEntry()
+	baseHash := EntryHash("prev", base)
+
+	tests := []struct {
+		name     string
+		prevHash string
+		change   func(*models.LedgerEntry)
+	}{
+		{"previous hash", "other", func(e *models.LedgerEntry) {}},
+		{"amount", "prev", func(e *models.LedgerEntry) { e.Amount = decimal.RequireFromString("25750.26") }},
+		{"quantity sign", "prev", func(e *models.LedgerEntry) { e.Quantity = e.Quantity.Neg() }},
+		{"nil price", "prev", func(e *models.LedgerEntry) { e.Price = decimal.NullDecimal{} }},
+		{"zero price", "prev", func(e *models.LedgerEntry) { e.Price = decimal.NewNullDecimal(decimal.Zero) }},
+		{"nil reward", "prev", func(e *models.LedgerEntry) { e.RewardID = nil }},
+		{"reward zero", "prev", func(e *models.LedgerEntry) { e.RewardID = int64Ptr(0) }},
+		{"nil journal", "prev", func(e *models.LedgerEntry) { e.JournalID = nil }},
+		{"dividend set", "prev", func(e *models.LedgerEntry) { e.DividendID = int64Ptr(1) }},
+		{"id", "prev", func(e *models.LedgerEntry) { e.ID = 8 }},
+		{"created at", "prev", func(e *models.LedgerEntry) { e.CreatedAt = e.CreatedAt.Add(time.Microsecond) }},
+		{"description", "prev", func(e *models.LedgerEntry) { e.Description += "." }},
+	}
+	seen := map[string]string{baseHash: "base"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := sampleEntry()
+			tt.change(&entry)
+			got := EntryHash(tt.prevHash, entry)
+			if other, ok := seen[got]; ok {
+				t.Fatalf("EntryHash() = %s, same as %s", got, other)
+			}
+			seen[got] = tt.name
+		})
+	}
+}
+
+// buildChain returns n correctly chained entries with IDs 1..n
+func buildChain(n int) []models.LedgerEntry {
+	entries := make([]models.LedgerEntry, n)
+	prev := ""
+	for i := range entries {
+		entry := sampleEntry()
+		entry.ID = int64(i + 1)
+		entry.Amount = decimal.NewFromInt(int64(100 * (i + 1)))
+		entry.PrevHash = prev
+		entry.Hash = EntryHash(prev, entry)
+		entries[i] = entry
+		prev = entry.Hash
+	}
+	return entries
+}
+
+// rechain recomputes the links and hashes from index from onward, as someone
+// with write access rewriting the ledger would
+func rechain(entries []models.LedgerEntry, from int) {
+	prev := ""
+	if from > 0 {
+		prev = entries[from-1].Hash
+	}
+	for i := from; i < len(entries); i++ {
+		entries[i].PrevHash = prev
+		entries[i].Hash = EntryHash(prev, entries[i])
+		prev = entries[i].Hash
+	}
+}
+
+func TestChainWalk(t *testing.T) {
+	tests := []struct {
+		name        string
+		tamper      func(entries []models.LedgerEntry) []models.LedgerEntry
+		wantEntry   int64
+		wantCheck   int64
+		wantChecked int
+	}{
+		{
+			name:        "intact",
+			tamper:      func(e []models.LedgerEntry) []models.LedgerEntry { return e },
+			wantChecked: 2,
+		},
+		{
+			name: "edited entry",
+			tamper: func(e []models.LedgerEntry) []models.LedgerEntry {
+				e[2].Amount = decimal.NewFromInt(1)
+				return e
+			},
+			wantEntry:   3,
+			wantChecked: 1,
+		},
+		{
+			name: "deleted entry",
+			tamper: func(e []models.LedgerEntry) []models.LedgerEntry {
+				return append(e[:1:1], e[2:]...)
+			},
+			wantEntry: 3,
+		},
+		{
+			name: "deleted tail",
+			tamper: func(e []models.LedgerEntry) []models.LedgerEntry {
+				return e[:4]
+			},
+			wantEntry:   6,
+			wantCheck:   2,
+			wantChecked: 1,
+		},
+		{
+			name: "rewritten suffix",
+			tamper: func(e []models.LedgerEntry) []models.LedgerEntry {
+				e[1].Amount = decimal.NewFromInt(1)
+				rechain(e, 1)
+				return e
+			},
+			wantEntry:   2,
+			wantCheck:   1,
+			wantChecked: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := buildChain(6)
+			checkpoints := []models.LedgerCheckpoint{
+				{ID: 1, LastEntryID: 2, Hash: entries[1].Hash, EntryCount: 2},
+				{ID: 2, LastEntryID: 6, Hash: entries[5].Hash, EntryCount: 6},
+			}
+			entries = tt.tamper(entries)
+
+			walk := newChainWalk(0, "", checkpoints)
+			for _, entry := range entries {
+				if !walk.next(entry) {
+					break
+				}
+			}
+			bad := walk.firstBad
+			if bad == nil {
+				bad = walk.missingCheckpoint(checkpoints)
+			}
+
+			if tt.wantEntry == 0 {
+				if bad != nil {
+					t.Fatalf("unexpected break at entry %d: %s", bad.EntryID, bad.Reason)
+				}
+			} else {
+				if bad == nil {
+					t.Fatalf("no break found, want entry %d", tt.wantEntry)
+				}
+				if bad.EntryID != tt.wantEntry || bad.CheckpointID != tt.wantCheck {
+					t.Fatalf("break at entry %d checkpoint %d (%s), want entry %d checkpoint %d",
+						bad.EntryID, bad.CheckpointID, bad.Reason, tt.wantEntry, tt.wantCheck)
+				}
+			}
+			if walk.checked != tt.wantChecked {
+				t.Fatalf("checked %d checkpoints, want %d", walk.checked, tt.wantChecked)
+			}
+		})
+	}
+}
+
+func TestChainWalkFromCheckpoint(t *testing.T) {
+	entries := buildChain(5)
+
+	walk := newChainWalk(entries[2].ID, entries[2].Hash, nil)
+	for _, entry := range entries[3:] {
+		if !walk.next(entry) {
+			t.Fatalf("unexpected break at entry %d: %s", walk.firstBad.EntryID, walk.firstBad.Reason)
+		}
+	}
+	if walk.entries != 2 || walk.lastID != 5 || walk.lastHash != entries[4].Hash {
+		t.Fatalf("walk ended at entry %d after %d entries, want entry 5 after 2", walk.lastID, walk.entries)
+	}
+}
+
+func TestCheckAnchors(t *testing.T) {
+	checkpoints := []models.LedgerCheckpoint{
+		{ID: 1, LastEntryID: 2, Hash: "aa"},
+		{ID: 2, LastEntryID: 6, Hash: "bb"},
+	}
+
+	tests := []struct {
+		name      string
+		anchors   []models.LedgerCheckpoint
+		wantCheck int64
+	}{
+		{"no anchor file", nil, 0},
+		{"matching", []models.LedgerCheckpoint{{ID: 1, LastEntryID: 2, Hash: "aa"}, {ID: 2, LastEntryID: 6, Hash: "bb"}}, 0},
+		{"checkpoint not anchored yet", []models.LedgerCheckpoint{{ID: 1, LastEntryID: 2, Hash: "aa"}}, 0},
+		{"deleted from database", []models.LedgerCheckpoint{{ID: 3, LastEntryID: 9, Hash: "cc"}}, 3},
+		{"hash rewritten", []models.LedgerCheckpoint{{ID: 2, LastEntryID: 6, Hash: "b0"}}, 2},
+		{"entry moved", []models.LedgerCheckpoint{{ID: 1, LastEntryID: 3, Hash: "aa"}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bad := checkAnchors(checkpoints, tt.anchors)
+			var got int64
+			if bad != nil {
+				got = bad.CheckpointID
+			}
+			if got != tt.wantCheck {
+				t.Fatalf("checkAnchors() broke at checkpoint %d, want %d", got, tt.wantCheck)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"stocky/models"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type accountTotals struct {
+	AccountCode string
+	Debit       decimal.Decimal
+	Credit      decimal.Decimal
+}
+
+// normalBalance expresses debit/credit totals in the account's normal direction
+func normalBalance(account models.Account, debit, credit decimal.Decimal) decimal.Decimal {
+	if account.DebitNormal() {
+		return debit.Sub(credit)
+	}
+	return credit.Sub(debit)
+}
+
+// postedBefore restricts ledger entries to journals posted before the given time
+func postedBefore(before time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Joins("JOIN journals j ON j.id = ledger_entries.journal_id").
+			Where("j.posted_at < ?", before)
+	}
+}
+
+func (s *LedgerService) totalsBefore(before time.Time, accountCode string) (map[string]accountTotals, error) {
+	query := s.db.Model(&models.LedgerEntry{}).
+		Scopes(postedBefore(before)).
+		Select(`ledger_entries.account_code,
+			COALESCE(SUM(CASE WHEN ledger_entries.amount > 0 THEN ledger_entries.amount ELSE 0 END), 0) AS debit,
+			COALESCE(SUM(CASE WHEN ledger_entries.amount < 0 THEN -ledger_entries.amount ELSE 0 END), 0) AS credit`).
+		Group("ledger_entries.account_code")
+	if accountCode != "" {
+		query = query.Where("ledger_entries.account_code = ?", accountCode)
+	}
+
+	var rows []accountTotals
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]accountTotals, len(rows))
+	for _, row := range rows {
+		totals[row.AccountCode] = row
+	}
+	return totals, nil
+}
+
+// ListAccounts returns the chart of accounts ordered by type and code
+func (s *LedgerService) ListAccounts() ([]models.Account, error) {
+	var accounts []models.Account
+	if err := s.db.Order("type, code").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// GetAccount returns an account by code
+func (s *LedgerService) GetAccount(code string) (*models.Account, error) {
+	var account models.Account
+	if err := s.db.Where("code = ?", code).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// TrialBalance returns debit/credit totals for every account from journals posted before the given time
+func (s *LedgerService) TrialBalance(before time.Time) ([]models.TrialBalanceLine, error) {
+	accounts, err := s.ListAccounts()
+	if err != nil {
+		return nil, err
+	}
+	totals, err := s.totalsBefore(before, "")
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]models.TrialBalanceLine, 0, len(accounts))
+	for _, account := range accounts {
+		t := totals[account.Code]
+		lines = append(lines, models.TrialBalanceLine{
+			AccountCode: account.Code,
+			AccountName: account.Name,
+			AccountType: account.Type,
+			Debit:       t.Debit,
+			Credit:      t.Credit,
+			Balance:     normalBalance(account, t.Debit, t.Credit),
+		})
+	}
+	return lines, nil
+}
+
+// AccountBalance returns an account's balance, in its normal direction, from journals posted before the given time
+func (s *LedgerService) AccountBalance(account models.Account, before time.Time) (decimal.Decimal, error) {
+	totals, err := s.totalsBefore(before, account.Code)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	t := totals[account.Code]
+	return normalBalance(account, t.Debit, t.Credit), nil
+}
+
+// GeneralLedger lists an account's postings in journals posted within
+// [from, to) with a running balance, starting from the opening balance at from
+func (s *LedgerService) GeneralLedger(account models.Account, from, to time.Time) (decimal.Decimal, []models.GeneralLedgerLine, error) {
+	opening, err := s.AccountBalance(account, from)
+	if err != nil {
+		return decimal.Zero, nil, err
+	}
+
+	var lines []models.GeneralLedgerLine
+	if err := s.db.Model(&models.LedgerEntry{}).
+		Scopes(postedBefore(to)).
+		Where("ledger_entries.account_code = ? AND j.posted_at >= ?", account.Code, from).
+		Select(`ledger_entries.id AS entry_id, ledger_entries.journal_id, j.reference, j.posted_at,
+			ledger_entries.entry_type, ledger_entries.user_id, ledger_entries.stock_symbol, ledger_entries.description,
+			CASE WHEN ledger_entries.amount > 0 THEN ledger_entries.amount ELSE 0 END AS debit,
+			CASE WHEN ledger_entries.amount < 0 THEN -ledger_entries.amount ELSE 0 END AS credit`).
+		Order("j.posted_at, ledger_entries.id").
+		Scan(&lines).Error; err != nil {
+		return decimal.Zero, nil, err
+	}
+
+	running := opening
+	for i := range lines {
+		running = running.Add(normalBalance(account, lines[i].Debit, lines[i].Credit))
+		lines[i].RunningBalance = running
+	}
+	return opening, lines, nil
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"stocky/models"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUnbalancedJournal = errors.New("journal does not balance")
+	ErrUnknownAccount    = errors.New("unknown account")
+)
+
+// ChartOfAccounts lists the accounts every deployment needs
+func ChartOfAccounts() []models.Account {
+	return []models.Account{
+		{Code: models.AccountCompanyCash, Name: "Company cash", Type: models.AccountTypeAsset},
+		{Code: models.AccountUserStockHoldings, Name: "Stock held for users", Type: models.AccountTypeAsset},
+		{Code: models.AccountGSTInputCredit, Name: "GST input credit", Type: models.AccountTypeAsset},
+		{Code: models.AccountUserDividendPayable, Name: "Dividends payable to users", Type: models.AccountTypeLiability},
+		{Code: models.AccountTDSPayable, Name: "TDS payable", Type: models.AccountTypeLiability},
+		{Code: models.AccountFeeExpense, Name: "Trading fees", Type: models.AccountTypeExpense},
+	}
+}
+
+// FeeAccount returns the account a fee component is debited to
+func FeeAccount(feeCode string) string {
+	if feeCode == models.FeeCodeGST {
+		return models.AccountGSTInputCredit
+	}
+	return models.AccountFeeExpense
+}
+
+type LedgerService struct {
+	db         *gorm.DB
+	anchorFile string // where checkpoints are anchored; "" logs them only
+}
+
+func NewLedgerService(db *gorm.DB, anchorFile string) *LedgerService {
+	return &LedgerService{db: db, anchorFile: anchorFile}
+}
+
+// EnsureChartOfAccounts creates any missing accounts from ChartOfAccounts
+func (s *LedgerService) EnsureChartOfAccounts() error {
+	accounts := ChartOfAccounts()
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}},
+		DoNothing: true,
+	}).Create(&accounts).Error
+}
+
+// Post validates a journal and writes it with its entries using tx, which
+// must be a transaction. Every entry must name a known account and the
+// signed amounts must sum to zero. The entries are appended to the hash
+// chain, so postings are serialised until tx ends.
+func (s *LedgerService) Post(tx *gorm.DB, journal *models.Journal) error {
+	if len(journal.Entries) == 0 {
+		return fmt.Errorf("%w: journal %q has no entries", ErrUnbalancedJournal, journal.Reference)
+	}
+
+	total := decimal.Zero
+	codes := make(map[string]bool)
+	for _, entry := range journal.Entries {
+		if entry.AccountCode == "" {
+			return fmt.Errorf("%w: %s entry has no account", ErrUnknownAccount, entry.EntryType)
+		}
+		codes[entry.AccountCode] = true
+		total = total.Add(entry.Amount)
+	}
+	if !total.IsZero() {
+		return fmt.Errorf("%w: %q is off by %s", ErrUnbalancedJournal, journal.Reference, total)
+	}
+
+	var known []string
+	if err := tx.Model(&models.Account{}).Where("code IN ?", keys(codes)).Pluck("code", &known).Error; err != nil {
+		return err
+	}
+	if len(known) != len(codes) {
+		for _, code := range known {
+			delete(codes, code)
+		}
+		return fmt.Errorf("%w: %s", ErrUnknownAccount, strings.Join(keys(codes), ", "))
+	}
+
+	if journal.PostedAt.IsZero() {
+		journal.PostedAt = time.Now()
+	}
+	if err := lockChain(tx); err != nil {
+		return err
+	}
+	if err := tx.Create(journal).Error; err != nil {
+		return err
+	}
+	return chainJournal(tx, journal.ID)
+}
+
+// GetJournal returns a journal with its entries
+func (s *LedgerService) GetJournal(id int64) (*models.Journal, error) {
+	var journal models.Journal
+	if err := s.db.Preload("Entries", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(&journal, id).Error; err != nil {
+		return nil, err
+	}
+	return &journal, nil
+}
+
+// AccountForEntryType maps entry types written before the chart of accounts
+// existed to the account they post to
+func AccountForEntryType(entryType string) string {
+	switch strings.TrimSuffix(entryType, models.ReversalSuffix) {
+	case models.EntryTypeStockCredit, models.EntryTypeCorporateAction:
+		return models.AccountUserStockHoldings
+	case models.EntryTypeCashDebit, models.EntryTypeFeeDebit:
+		return models.AccountCompanyCash
+	}
+	return ""
+}
+
+// BackfillJournals groups reward and corporate-action entries written before
+// journals existed into balanced journals. Legacy fee debits were booked
+// against cash only, so a matching FEE_EXPENSE entry is added for each.
+func (s *LedgerService) BackfillJournals() error {
+	var legacy []models.LedgerEntry
+	if err := s.db.Where("journal_id IS NULL AND (reward_id IS NOT NULL OR corporate_action_id IS NOT NULL)").
+		Order("id").Find(&legacy).Error; err != nil {
+		return err
+	}
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	type group struct {
+		reference string
+		entries   []models.LedgerEntry
+	}
+	var order []string
+	groups := make(map[string]*group)
+	for _, entry := range legacy {
+		var ref string
+		switch {
+		case entry.CorporateActionID != nil:
+			ref = fmt.Sprintf("corporate_action:%d", *entry.CorporateActionID)
+		case entry.ReversesID != nil:
+			ref = fmt.Sprintf("reward:%d:reversal", *entry.RewardID)
+		default:
+			ref = fmt.Sprintf("reward:%d", *entry.RewardID)
+		}
+		if groups[ref] == nil {
+			groups[ref] = &group{reference: ref}
+			order = append(order, ref)
+		}
+		groups[ref].entries = append(groups[ref].entries, entry)
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, ref := range order {
+			g := groups[ref]
+			journal := models.Journal{
+				Reference:   g.reference,
+				Description: "Backfilled from legacy ledger entries",
+				PostedAt:    g.entries[0].CreatedAt,
+			}
+			if err := tx.Create(&journal).Error; err != nil {
+				return err
+			}
+
+			total := decimal.Zero
+			for _, entry := range g.entries {
+				entry.JournalID = &journal.ID
+				entry.AccountCode = AccountForEntryType(entry.EntryType)
+				if err := tx.Model(&entry).Select("journal_id", "account_code").Updates(&entry).Error; err != nil {
+					return err
+				}
+				total = total.Add(entry.Amount)
+
+				base := strings.TrimSuffix(entry.EntryType, models.ReversalSuffix)
+				if base != models.EntryTypeFeeDebit {
+					continue
+				}
+				expense := models.LedgerEntry{
+					JournalID:   &journal.ID,
+					AccountCode: FeeAccount(entry.FeeCode),
+					RewardID:    entry.RewardID,
+					UserID:      entry.UserID,
+					EntryType:   strings.Replace(entry.EntryType, models.EntryTypeFeeDebit, models.EntryTypeFeeExpense, 1),
+					StockSymbol: entry.StockSymbol,
+					FeeCode:     entry.FeeCode,
+					Quantity:    decimal.Zero,
+					Amount:      entry.Amount.Neg(),
+					Description: entry.Description,
+				}
+				if err := tx.Create(&expense).Error; err != nil {
+					return err
+				}
+				total = total.Add(expense.Amount)
+			}
+			if !total.IsZero() {
+				return fmt.Errorf("%w: backfilled %q is off by %s", ErrUnbalancedJournal, ref, total)
+			}
+		}
+
+		logrus.Infof("Backfilled %d journals from legacy ledger entries", len(order))
+		return nil
+	})
+}
+
+func keys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"stocky/config"
+	"strings"
+	"time"
+)
+
+// IST is the exchange time zone. India has no daylight saving, so a fixed
+// offset is exact.
+var IST = time.FixedZone("IST", 5*60*60+30*60)
+
+// MarketCalendar knows which days the exchange trades and its session hours.
+// Days are calendar dates; the time of day and location of a date argument
+// are ignored.
+type MarketCalendar struct {
+	open     time.Duration // session open, after midnight IST
+	close    time.Duration // session close, after midnight IST
+	holidays map[string]string
+}
+
+// NewMarketCalendar builds a calendar with sessions from open to close
+// (HH:MM, IST) on weekdays that are not in holidays (date to name)
+func NewMarketCalendar(open, close string, holidays map[string]string) (*MarketCalendar, error) {
+	openAt, err := parseSessionTime(open)
+	if err != nil {
+		return nil, err
+	}
+	closeAt, err := parseSessionTime(close)
+	if err != nil {
+		return nil, err
+	}
+	if closeAt <= openAt {
+		return nil, fmt.Errorf("market close %s must be after open %s", close, open)
+	}
+	if holidays == nil {
+		holidays = make(map[string]string)
+	}
+	return &MarketCalendar{open: openAt, close: closeAt, holidays: holidays}, nil
+}
+
+// LoadMarketCalendar builds the calendar from cfg, reading holidays from
+// cfg.HolidaysFile if set
+func LoadMarketCalendar(cfg *config.Config) (*MarketCalendar, error) {
+	var holidays map[string]string
+	if cfg.HolidaysFile != "" {
+		f, err := os.Open(cfg.HolidaysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open holidays file: %w", err)
+		}
+		defer f.Close()
+
+		if holidays, err = ParseHolidays(f); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.HolidaysFile, err)
+		}
+	}
+	return NewMarketCalendar(cfg.MarketOpen, cfg.MarketClose, holidays)
+}
+
+// ParseHolidays reads a CSV of date (YYYY-MM-DD) and name with a header row.
+// Lines starting with # are comments.
+func ParseHolidays(r io.Reader) (map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	holidays := make(map[string]string)
+	if _, err := reader.Read(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday date %q", record[0])
+		}
+		name := ""
+		if len(record) > 1 {
+			name = strings.TrimSpace(record[1])
+		}
+		holidays[date.Format("2006-01-02")] = name
+	}
+	return holidays, nil
+}
+
+// Holiday returns the name of the exchange holiday on date, if any
+func (c *MarketCalendar) Holiday(date time.Time) (string, bool) {
+	name, ok := c.holidays[date.Format("2006-01-02")]
+	return name, ok
+}
+
+// IsTradingDay reports whether the exchange trades on date
+func (c *MarketCalendar) IsTradingDay(date time.Time) bool {
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return false
+	}
+	_, holiday := c.Holiday(date)
+	return !holiday
+}
+
+// LastTradingDay returns the latest trading day on or before date
+func (c *MarketCalendar) LastTradingDay(date time.Time) time.Time {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	for !c.IsTradingDay(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// ISTDayStart returns midnight IST at the start of date's calendar day.
+// Dates read from a date column come back as UTC midnight, which is 05:30 IST.
+func ISTDayStart(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, IST)
+}
+
+// SessionOpen returns when the session opens on date
+func (c *MarketCalendar) SessionOpen(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, IST).Add(c.open)
+}
+
+// SessionClose returns when the session closes on date
+func (c *MarketCalendar) SessionClose(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, IST).Add(c.close)
+}
+
+// InSession reports whether the market is open at t
+func (c *MarketCalendar) InSession(t time.Time) bool {
+	t = t.In(IST)
+	if !c.IsTradingDay(t) {
+		return false
+	}
+	return !t.Before(c.SessionOpen(t)) && t.Before(c.SessionClose(t))
+}
+
+// LastMarketTime returns t if the market is open at t, otherwise the most
+// recent session close before t. Prices can't move after this time.
+func (c *MarketCalendar) LastMarketTime(t time.Time) time.Time {
+	if c.InSession(t) {
+		return t
+	}
+	day := t.In(IST)
+	if !c.IsTradingDay(day) || day.Before(c.SessionClose(day)) {
+		day = c.LastTradingDay(day.AddDate(0, 0, -1))
+	}
+	return c.SessionClose(day)
+}
+
+func parseSessionTime(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, errors.New("session time must be HH:MM, got " + value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
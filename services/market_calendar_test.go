@@ -0,0 +1,116 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCalendar(t *testing.T) *MarketCalendar {
+	t.Helper()
+	holidays, err := ParseHolidays(strings.NewReader("date,name\n# comment\n2025-11-05, Guru Nanak Jayanti\n"))
+	if err != nil {
+		t.Fatalf("ParseHolidays: %v", err)
+	}
+	calendar, err := NewMarketCalendar("09:15", "15:30", holidays)
+	if err != nil {
+		t.Fatalf("NewMarketCalendar: %v", err)
+	}
+	return calendar
+}
+
+func ist(day, hour, min int) time.Time {
+	return time.Date(2025, time.November, day, hour, min, 0, 0, IST)
+}
+
+func utc(day, hour, min int) time.Time {
+	return time.Date(2025, time.November, day, hour, min, 0, 0, time.UTC)
+}
+
+func TestIsTradingDay(t *testing.T) {
+	calendar := testCalendar(t)
+	tests := []struct {
+		name string
+		date time.Time
+		want bool
+	}{
+		{"monday", ist(10, 0, 0), true},
+		{"friday", ist(7, 0, 0), true},
+		{"saturday", ist(8, 0, 0), false},
+		{"sunday", ist(9, 0, 0), false},
+		{"holiday", ist(5, 0, 0), false},
+		{"day after holiday", ist(6, 0, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calendar.IsTradingDay(tt.date); got != tt.want {
+				t.Fatalf("IsTradingDay(%s) = %v, want %v", tt.date.Format("Mon 2006-01-02"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInSession(t *testing.T) {
+	calendar := testCalendar(t)
+	tests := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{"before open", ist(10, 9, 14), false},
+		{"at open", ist(10, 9, 15), true},
+		{"midday", ist(10, 12, 0), true},
+		{"last minute", ist(10, 15, 29), true},
+		{"at close", ist(10, 15, 30), false},
+		{"evening", ist(10, 20, 0), false},
+		{"saturday midday", ist(8, 12, 0), false},
+		{"holiday midday", ist(5, 12, 0), false},
+		{"UTC during the IST session", utc(10, 4, 0), true},
+		{"UTC before the IST open", utc(10, 3, 44), false},
+		{"UTC sunday evening is early monday in IST", utc(9, 20, 0), false},
+		{"UTC last minute of the IST session", utc(10, 9, 59), true},
+		{"UTC friday evening is saturday in IST", utc(7, 18, 45), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calendar.InSession(tt.at); got != tt.want {
+				t.Fatalf("InSession(%s) = %v, want %v", tt.at.In(IST).Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLastMarketTime(t *testing.T) {
+	calendar := testCalendar(t)
+	tests := []struct {
+		name string
+		at   time.Time
+		want time.Time
+	}{
+		{"in session", ist(10, 11, 0), ist(10, 11, 0)},
+		{"monday before open", ist(10, 8, 0), ist(7, 15, 30)},
+		{"after close", ist(10, 18, 0), ist(10, 15, 30)},
+		{"at close", ist(10, 15, 30), ist(10, 15, 30)},
+		{"saturday", ist(8, 12, 0), ist(7, 15, 30)},
+		{"sunday night", ist(9, 23, 59), ist(7, 15, 30)},
+		{"holiday", ist(5, 12, 0), ist(4, 15, 30)},
+		{"morning after holiday", ist(6, 8, 0), ist(4, 15, 30)},
+		{"UTC early monday is before the IST open", utc(10, 2, 0), ist(7, 15, 30)},
+		{"UTC sunday evening is early monday in IST", utc(9, 20, 0), ist(7, 15, 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calendar.LastMarketTime(tt.at); !got.Equal(tt.want) {
+				t.Fatalf("LastMarketTime(%s) = %s, want %s", tt.at.In(IST).Format("Mon 01-02 15:04"), got.In(IST).Format("Mon 01-02 15:04"), tt.want.Format("Mon 01-02 15:04"))
+			}
+		})
+	}
+}
+
+func TestISTDayStart(t *testing.T) {
+	// A date column reads back as UTC midnight
+	got := ISTDayStart(time.Date(2025, time.November, 10, 0, 0, 0, 0, time.UTC))
+	if want := utc(9, 18, 30); !got.Equal(want) {
+		t.Fatalf("ISTDayStart = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"stocky/config"
+	"stocky/models"
+
+	"github.com/shopspring/decimal"
+)
+
+// PriceProvider is a source of stock quotes used by StockPriceService
+type PriceProvider interface {
+	// Name identifies the provider (e.g. "random", "file", "http")
+	Name() string
+	// FetchPrice returns the latest price for a symbol in INR
+	FetchPrice(symbol string) (decimal.Decimal, error)
+}
+
+// NewPriceProvider builds the provider selected by cfg.PriceProvider
+func NewPriceProvider(cfg *config.Config) (PriceProvider, error) {
+	switch strings.ToLower(cfg.PriceProvider) {
+	case "", "random":
+		return NewRandomPriceProvider(), nil
+	case "file":
+		if cfg.PriceFile == "" {
+			return nil, fmt.Errorf("PRICE_FILE must be set for the file price provider")
+		}
+		return NewFilePriceProvider(cfg.PriceFile), nil
+	case "http":
+		if cfg.PriceAPIURL == "" {
+			return nil, fmt.Errorf("PRICE_API_URL must be set for the http price provider")
+		}
+		return NewHTTPPriceProvider(cfg.PriceAPIURL, cfg.PriceAPITimeout), nil
+	default:
+		return nil, fmt.Errorf("unknown price provider %q", cfg.PriceProvider)
+	}
+}
+
+// RandomPriceProvider generates hypothetical prices as a ±5% walk around a base price
+type RandomPriceProvider struct {
+	mu   sync.Mutex
+	rng  *rand.Rand
+	base map[string]float64
+}
+
+func NewRandomPriceProvider() *RandomPriceProvider {
+	return &RandomPriceProvider{
+		rng: rand.New(rand.NewSource(time.Now().UnixNano())),
+		// Base prices for common Indian stocks
+		base: map[string]float64{
+			"RELIANCE": 2400.0,
+			"TCS":      3500.0,
+			"INFOSYS":  1500.0,
+			"HDFC":     1600.0,
+			"ICICI":    900.0,
+			"SBI":      600.0,
+			"WIPRO":    400.0,
+			"BHARTI":   800.0,
+			"ITC":      450.0,
+			"HCLTECH":  1200.0,
+		},
+	}
+}
+
+func (p *RandomPriceProvider) Name() string {
+	return "random"
+}
+
+func (p *RandomPriceProvider) FetchPrice(symbol string) (decimal.Decimal, error) {
+	base, exists := p.base[symbol]
+	if !exists {
+		base = 1000.0 // Default base price
+	}
+
+	p.mu.Lock()
+	variation := (p.rng.Float64() - 0.5) * 0.1 // -5% to +5%
+	p.mu.Unlock()
+
+	return models.RoundAmount(decimal.NewFromFloat(base * (1 + variation))), nil
+}
+
+// FilePriceProvider reads prices from a JSON file mapping symbol to price,
+// e.g. {"RELIANCE": 2451.30, "TCS": 3498.00}. The file is re-read on every
+// fetch so it can be edited while the server is running.
+type FilePriceProvider struct {
+	path string
+}
+
+func NewFilePriceProvider(path string) *FilePriceProvider {
+	return &FilePriceProvider{path: path}
+}
+
+func (p *FilePriceProvider) Name() string {
+	return "file"
+}
+
+func (p *FilePriceProvider) FetchPrice(symbol string) (decimal.Decimal, error) {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to read price file: %w", err)
+	}
+
+	prices, err := parsePriceFile(data)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid price file %s: %w", p.path, err)
+	}
+
+	price, exists := prices[symbol]
+	if !exists {
+		return decimal.Zero, fmt.Errorf("no price for %s in %s", symbol, p.path)
+	}
+	return price, nil
+}
+
+// parsePriceFile reads a JSON object mapping symbol to price. Every price
+// must be positive; a bad one fails the file with its line number.
+func parsePriceFile(data []byte) (map[string]decimal.Decimal, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, errors.New("must be a JSON object mapping symbol to price")
+	}
+
+	prices := make(map[string]decimal.Decimal)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		symbol := tok.(string)
+		line := 1 + bytes.Count(data[:dec.InputOffset()], []byte("\n"))
+
+		var price decimal.Decimal
+		if err := dec.Decode(&price); err != nil {
+			return nil, fmt.Errorf("line %d: price for %s is not a number", line, symbol)
+		}
+		if !price.IsPositive() {
+			return nil, fmt.Errorf("line %d: price for %s must be positive, got %s", line, symbol, price)
+		}
+		prices[symbol] = price
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+// HTTPPriceProvider fetches quotes from GET {baseURL}/prices/{symbol},
+// which must respond with {"symbol": "TCS", "price": 3500.25}
+type HTTPPriceProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewHTTPPriceProvider(baseURL string, timeout time.Duration) *HTTPPriceProvider {
+	return &HTTPPriceProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *HTTPPriceProvider) Name() string {
+	return "http"
+}
+
+// PriceQuote is the payload returned by an HTTP price API
+type PriceQuote struct {
+	Symbol string          `json:"symbol"`
+	Price  decimal.Decimal `json:"price"`
+}
+
+func (p *HTTPPriceProvider) FetchPrice(symbol string) (decimal.Decimal, error) {
+	resp, err := p.client.Get(p.baseURL + "/prices/" + url.PathEscape(symbol))
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to call price API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return decimal.Zero, fmt.Errorf("price API returned %s for %s", resp.Status, symbol)
+	}
+
+	var quote PriceQuote
+	if err := json.NewDecoder(resp.Body).Decode(&quote); err != nil {
+		return decimal.Zero, fmt.Errorf("failed to decode price API response: %w", err)
+	}
+	if !quote.Price.IsPositive() {
+		return decimal.Zero, fmt.Errorf("price API returned invalid price %s for %s", quote.Price, symbol)
+	}
+	return quote.Price, nil
+}
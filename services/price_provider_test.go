@@ -0,0 +1,47 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilePriceProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    string
+		wantErr string
+	}{
+		{"valid", "{\n  \"TCS\": 3498.00,\n  \"RELIANCE\": \"2451.30\"\n}", "3498", ""},
+		{"zero price", "{\n  \"RELIANCE\": 2451.30,\n  \"TCS\": 0\n}", "", "line 3: price for TCS must be positive"},
+		{"negative price", "{\"TCS\": -1}", "", "line 1: price for TCS must be positive"},
+		{"bad price elsewhere in the file", "{\n  \"TCS\": 3498,\n\n  \"INFY\": -5\n}", "", "line 4: price for INFY must be positive"},
+		{"not a number", "{\n  \"TCS\": true\n}", "", "line 2: price for TCS is not a number"},
+		{"not an object", "[3498]", "", "must be a JSON object"},
+		{"missing symbol", "{\"RELIANCE\": 2451.30}", "", "no price for TCS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "prices.json")
+			if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			price, err := NewFilePriceProvider(path).FetchPrice("TCS")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("FetchPrice() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchPrice() error = %v", err)
+			}
+			if price.String() != tt.want {
+				t.Fatalf("FetchPrice() = %s, want %s", price, tt.want)
+			}
+		})
+	}
+}
@@ -1,138 +1,405 @@
 package services
 
 import (
-	"math/rand"
+	"context"
+	"errors"
+	"fmt"
+	"stocky/audit"
+	"stocky/metrics"
 	"stocky/models"
+	"sync"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrInvalidPriceOverride = errors.New("invalid price override")
+
+// PriceUpdateInterval is how often the background updater runs
+const PriceUpdateInterval = time.Hour
+
 type StockPriceService struct {
-	db *gorm.DB
+	db         *gorm.DB
+	provider   PriceProvider
+	staleAfter time.Duration
+	calendar   *MarketCalendar
+
+	statusMu sync.Mutex
+	status   models.PriceUpdaterStatus
 }
 
-func NewStockPriceService(db *gorm.DB) *StockPriceService {
-	return &StockPriceService{db: db}
+func NewStockPriceService(db *gorm.DB, provider PriceProvider, staleAfter time.Duration, calendar *MarketCalendar) *StockPriceService {
+	return &StockPriceService{db: db, provider: provider, staleAfter: staleAfter, calendar: calendar}
 }
 
-// StartPriceUpdater runs hourly to update stock prices
-func (s *StockPriceService) StartPriceUpdater() {
-	logrus.Info("Starting stock price updater (runs every hour)")
+// StartPriceUpdater runs hourly to update stock prices while the market is
+// open. Outside sessions prices stay frozen on the close. It returns when
+// ctx is cancelled.
+func (s *StockPriceService) StartPriceUpdater(ctx context.Context) {
+	logrus.Info("Starting stock price updater (runs every hour during market sessions)")
 
 	// Update immediately on startup
-	s.updateAllPrices()
+	s.tick(ctx, time.Now())
 
 	// Then update every hour
-	ticker := time.NewTicker(1 * time.Hour)
+	ticker := time.NewTicker(PriceUpdateInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		s.updateAllPrices()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.tick(ctx, now)
+		}
 	}
 }
 
-// updateAllPrices fetches and updates prices for all stocks
-func (s *StockPriceService) updateAllPrices() {
+// tick refreshes prices during a session. After a session ends, the first
+// tick takes one last snapshot stamped at the close, then prices freeze
+// until the next session opens.
+func (s *StockPriceService) tick(ctx context.Context, now time.Time) {
+	if s.calendar.InSession(now) {
+		s.updateAllPrices(ctx, now)
+		return
+	}
+
+	lastClose := s.calendar.LastMarketTime(now)
+	var latest *time.Time
+	if err := s.db.Model(&models.StockPrice{}).Select("MAX(updated_at)").Row().Scan(&latest); err != nil {
+		logrus.Errorf("Failed to check latest price time: %v", err)
+		s.recordRun(fmt.Errorf("failed to check latest price time: %w", err))
+		return
+	}
+	if latest == nil || latest.Before(lastClose) {
+		s.updateAllPrices(ctx, lastClose)
+		return
+	}
+	logrus.Debugf("Market closed; prices frozen at the %s close", lastClose.Format(time.RFC3339))
+	s.recordRun(nil)
+}
+
+// updateAllPrices fetches and records prices for all stocks as observed at
+// observedAt, stopping early if ctx is cancelled
+func (s *StockPriceService) updateAllPrices(ctx context.Context, observedAt time.Time) {
 	logrus.Info("Updating stock prices...")
+	start := time.Now()
+	defer func() { metrics.PriceUpdateDuration.Observe(time.Since(start).Seconds()) }()
 
-	// Get all unique stock symbols from rewards
+	// Track every security that is still listed
 	var symbols []string
-	if err := s.db.Model(&models.StockReward{}).
-		Distinct("stock_symbol").
-		Pluck("stock_symbol", &symbols).Error; err != nil {
+	if err := s.db.Model(&models.Security{}).
+		Where("status <> ?", models.SecurityStatusDelisted).
+		Order("symbol").
+		Pluck("symbol", &symbols).Error; err != nil {
 		logrus.Errorf("Failed to fetch stock symbols: %v", err)
+		metrics.PriceUpdateRuns.WithLabelValues(metrics.ResultFailed).Inc()
+		s.recordRun(fmt.Errorf("failed to fetch stock symbols: %w", err))
 		return
 	}
 
-	// Update price for each symbol
-	for _, symbol := range symbols {
-		price := s.getStockPrice(symbol)
+	// Record price for each symbol
+	failed := 0
+	for i, symbol := range symbols {
+		if ctx.Err() != nil {
+			logrus.Infof("Price update interrupted after %d of %d stocks", i, len(symbols))
+			metrics.PriceUpdateRuns.WithLabelValues(metrics.ResultInterrupted).Inc()
+			return
+		}
 
-		var stockPrice models.StockPrice
-		result := s.db.Where("stock_symbol = ?", symbol).First(&stockPrice)
+		price, err := s.getStockPrice(symbol)
+		if err != nil {
+			logrus.Errorf("Failed to fetch price for %s: %v", symbol, err)
+			metrics.PriceUpdates.WithLabelValues(symbol, metrics.ResultFailed).Inc()
+			failed++
+			continue
+		}
 
-		if result.Error == gorm.ErrRecordNotFound {
-			// Create new price record
-			stockPrice = models.StockPrice{
-				StockSymbol: symbol,
-				Price:       price,
-				UpdatedAt:   time.Now(),
-			}
-			if err := s.db.Create(&stockPrice).Error; err != nil {
-				logrus.Errorf("Failed to create price for %s: %v", symbol, err)
-			}
-		} else {
-			// Update existing price record
-			stockPrice.Price = price
-			stockPrice.UpdatedAt = time.Now()
-			if err := s.db.Save(&stockPrice).Error; err != nil {
-				logrus.Errorf("Failed to update price for %s: %v", symbol, err)
-			}
+		if _, err := s.RecordPrice(ctx, symbol, price, s.provider.Name(), observedAt); err != nil {
+			logrus.Errorf("Failed to record price for %s: %v", symbol, err)
+			metrics.PriceUpdates.WithLabelValues(symbol, metrics.ResultFailed).Inc()
+			failed++
+			continue
 		}
+		metrics.PriceUpdates.WithLabelValues(symbol, metrics.ResultSuccess).Inc()
+	}
+
+	logrus.Infof("Updated prices for %d stocks", len(symbols)-failed)
+
+	// A run that priced nothing has failed; partial failures are reported
+	// but still count as a successful run
+	var err error
+	if failed > 0 && failed == len(symbols) {
+		err = fmt.Errorf("no prices recorded for %d symbols", failed)
+		metrics.PriceUpdateRuns.WithLabelValues(metrics.ResultFailed).Inc()
+	} else {
+		metrics.PriceUpdateRuns.WithLabelValues(metrics.ResultSuccess).Inc()
+		metrics.PriceLastSuccess.SetToCurrentTime()
 	}
+	s.statusMu.Lock()
+	s.status.Symbols, s.status.Failed = len(symbols), failed
+	s.statusMu.Unlock()
+	s.recordRun(err)
+}
 
-	logrus.Infof("Updated prices for %d stocks", len(symbols))
+// recordRun notes the outcome of a price updater run
+func (s *StockPriceService) recordRun(err error) {
+	now := time.Now()
+
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	s.status.LastRunAt = &now
+	if err != nil {
+		s.status.LastError = err.Error()
+		return
+	}
+	s.status.LastSuccessAt = &now
+	s.status.LastError = ""
 }
 
-// getStockPrice generates a hypothetical stock price
-func (s *StockPriceService) getStockPrice(symbol string) float64 {
-	// Seed based on time for variation
-	rand.Seed(time.Now().UnixNano())
+// UpdaterStatus returns the outcome of the background updater's recent runs
+func (s *StockPriceService) UpdaterStatus() models.PriceUpdaterStatus {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	return s.status
+}
 
-	// Base prices for common Indian stocks
-	basePrice := map[string]float64{
-		"RELIANCE": 2400.0,
-		"TCS":      3500.0,
-		"INFOSYS":  1500.0,
-		"HDFC":     1600.0,
-		"ICICI":    900.0,
-		"SBI":      600.0,
-		"WIPRO":    400.0,
-		"BHARTI":   800.0,
-		"ITC":      450.0,
-		"HCLTECH":  1200.0,
+// getStockPrice fetches the latest price for a symbol from the configured provider
+func (s *StockPriceService) getStockPrice(symbol string) (decimal.Decimal, error) {
+	price, err := s.provider.FetchPrice(symbol)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("%s provider: %w", s.provider.Name(), err)
 	}
+	return models.RoundAmount(price), nil
+}
 
-	base, exists := basePrice[symbol]
-	if !exists {
-		base = 1000.0 // Default base price
+// RecordPrice appends a quote to price history and refreshes the latest
+// price for the symbol if the quote is newer than what is stored
+func (s *StockPriceService) RecordPrice(ctx context.Context, symbol string, price decimal.Decimal, source string, observedAt time.Time) (*models.PriceHistory, error) {
+	entry := models.PriceHistory{
+		StockSymbol: symbol,
+		Price:       price,
+		Source:      source,
+		ObservedAt:  observedAt,
 	}
 
-	// Add random variation of ±5%
-	variation := (rand.Float64() - 0.5) * 0.1 // -5% to +5%
-	price := base * (1 + variation)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before *models.StockPrice
+		var latest models.StockPrice
+		err := tx.Where("stock_symbol = ?", symbol).First(&latest).Error
+		if err == nil {
+			before = &latest
+		} else if err != gorm.ErrRecordNotFound {
+			return err
+		}
 
-	return roundToDecimal(price, 2)
+		if err := tx.Create(&entry).Error; err != nil {
+			return fmt.Errorf("failed to append price history: %w", err)
+		}
+		if err := updateLatestPrice(tx, symbol, price, source, observedAt); err != nil {
+			return err
+		}
+
+		action := models.AuditPriceRecord
+		if source == models.PriceSourceManual {
+			action = models.AuditPriceOverride
+		}
+		return audit.Record(tx, action, "stock_price", symbol, before, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
 }
 
-// GetCurrentPrice returns the current price for a symbol
-func (s *StockPriceService) GetCurrentPrice(symbol string) (float64, error) {
+// updateLatestPrice stores price as the latest for symbol unless a newer
+// price is already stored; late-arriving quotes stay in history only. At the
+// same timestamp only an official close or an admin override replaces the
+// stored price, so a provider quote can't overwrite a session close.
+func updateLatestPrice(tx *gorm.DB, symbol string, price decimal.Decimal, source string, observedAt time.Time) error {
+	latest := models.StockPrice{
+		StockSymbol: symbol,
+		Price:       price,
+		Source:      source,
+		UpdatedAt:   observedAt,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "stock_symbol"}},
+		DoUpdates: clause.AssignmentColumns([]string{"price", "source", "updated_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{
+				SQL:  "stock_prices.updated_at < EXCLUDED.updated_at OR (stock_prices.updated_at = EXCLUDED.updated_at AND EXCLUDED.source IN (?, ?))",
+				Vars: []interface{}{models.PriceSourceBhavcopy, models.PriceSourceManual},
+			},
+		}},
+	}).Create(&latest).Error
+}
+
+// OverridePrice records a price set by an admin for a symbol in the
+// securities master. observedAt defaults to now and can't be in the future.
+func (s *StockPriceService) OverridePrice(ctx context.Context, symbol string, price decimal.Decimal, observedAt *time.Time) (*models.PriceHistory, error) {
+	if !price.IsPositive() {
+		return nil, fmt.Errorf("%w: price must be positive", ErrInvalidPriceOverride)
+	}
+	now := time.Now()
+	at := now
+	if observedAt != nil {
+		if observedAt.After(now) {
+			return nil, fmt.Errorf("%w: observed_at is in the future", ErrInvalidPriceOverride)
+		}
+		at = *observedAt
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Security{}).Where("symbol = ?", symbol).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("%w: %q is not in the securities master", ErrUnknownSymbol, symbol)
+	}
+
+	entry, err := s.RecordPrice(ctx, symbol, models.RoundAmount(price), models.PriceSourceManual, at)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Warnf("Price of %s overridden to %s as of %s", symbol, entry.Price, at.Format(time.RFC3339))
+	return entry, nil
+}
+
+// IsStale reports whether a price observed at observedAt is too old to use.
+// Age is measured to now during a session and to the last close otherwise,
+// so the closing price stays fresh until the next session.
+func (s *StockPriceService) IsStale(observedAt time.Time) bool {
+	if s.staleAfter <= 0 {
+		return false
+	}
+	return s.calendar.LastMarketTime(time.Now()).Sub(observedAt) > s.staleAfter
+}
+
+// GetQuote returns the current price for a symbol with its timestamp. During
+// a session a missing or stale price is fetched from the provider; if that
+// fails, a stale price is still returned, marked Stale. Outside a session
+// prices are frozen, so the stored price is served as is and the provider is
+// only asked for symbols that have no price yet.
+func (s *StockPriceService) GetQuote(symbol string) (*models.Quote, error) {
 	var stockPrice models.StockPrice
-	if err := s.db.Where("stock_symbol = ?", symbol).First(&stockPrice).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			// If price doesn't exist, generate and save it
-			price := s.getStockPrice(symbol)
-			stockPrice = models.StockPrice{
-				StockSymbol: symbol,
-				Price:       price,
-				UpdatedAt:   time.Now(),
-			}
-			if err := s.db.Create(&stockPrice).Error; err != nil {
-				return 0, err
+	err := s.db.Where("stock_symbol = ?", symbol).First(&stockPrice).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	if err == nil {
+		stale := s.IsStale(stockPrice.UpdatedAt)
+		if !stale || !s.calendar.InSession(time.Now()) {
+			return &models.Quote{Price: stockPrice.Price, AsOf: stockPrice.UpdatedAt, Stale: stale}, nil
+		}
+	}
+
+	price, fetchErr := s.getStockPrice(symbol)
+	if fetchErr == nil {
+		// Outside a session the fetched price is the close
+		observedAt := s.calendar.LastMarketTime(time.Now())
+		if _, fetchErr = s.RecordPrice(context.Background(), symbol, price, s.provider.Name(), observedAt); fetchErr == nil {
+			return &models.Quote{Price: price, AsOf: observedAt}, nil
+		}
+	}
+	if err == gorm.ErrRecordNotFound {
+		return nil, fetchErr
+	}
+
+	logrus.Warnf("Using stale price for %s from %s: %v", symbol, stockPrice.UpdatedAt.Format(time.RFC3339), fetchErr)
+	return &models.Quote{Price: stockPrice.Price, AsOf: stockPrice.UpdatedAt, Stale: true}, nil
+}
+
+// GetPriceAt returns the most recent price observed for a symbol at or before the given time
+func (s *StockPriceService) GetPriceAt(symbol string, at time.Time) (*models.PriceHistory, error) {
+	var entry models.PriceHistory
+	if err := s.db.Where("stock_symbol = ? AND observed_at <= ?", symbol, at).
+		Order("observed_at DESC, id DESC").
+		First(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetPriceHistory returns prices observed for a symbol within [from, to), oldest first
+func (s *StockPriceService) GetPriceHistory(symbol string, from, to time.Time) ([]models.PriceHistory, error) {
+	var entries []models.PriceHistory
+	if err := s.db.Where("stock_symbol = ? AND observed_at >= ? AND observed_at < ?", symbol, from, to).
+		Order("observed_at, id").
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetDailyClosingPrices returns the closing price of a symbol for each day
+// starting at from for the given number of days. A day with an imported
+// bhavcopy close uses it; otherwise the close is the last price observed on
+// or before the end of that day, so days without a quote carry the previous
+// close forward. Weekends and holidays take the last trading day's close,
+// which is the official one when it was imported. Days before the first
+// known quote are 0.
+func (s *StockPriceService) GetDailyClosingPrices(symbol string, from time.Time, days int) ([]decimal.Decimal, error) {
+	closes := make([]decimal.Decimal, days)
+	if days <= 0 {
+		return closes, nil
+	}
+
+	// Seed with the last price known before the range starts
+	last := decimal.Zero
+	if seed, err := s.GetPriceAt(symbol, from); err == nil {
+		last = seed.Price
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	history, err := s.GetPriceHistory(symbol, from, from.AddDate(0, 0, days))
+	if err != nil {
+		return nil, err
+	}
+	official, err := s.GetDailyPrices(symbol, from, from.AddDate(0, 0, days))
+	if err != nil {
+		return nil, err
+	}
+	byDate := make(map[string]decimal.Decimal, len(official))
+	for _, daily := range official {
+		byDate[daily.TradeDate.Format("2006-01-02")] = daily.Close
+	}
+
+	i := 0
+	for d := 0; d < days; d++ {
+		day := from.AddDate(0, 0, d)
+		endOfDay := from.AddDate(0, 0, d+1)
+		trading := s.calendar.IsTradingDay(day)
+		for i < len(history) && history[i].ObservedAt.Before(endOfDay) {
+			// Quotes on non-trading days don't move the close
+			if trading {
+				last = history[i].Price
 			}
-			return price, nil
+			i++
+		}
+		// Official closes override whatever was observed during the day and
+		// carry forward to the days after it
+		if close, ok := byDate[day.Format("2006-01-02")]; ok {
+			last = close
 		}
-		return 0, err
+		closes[d] = last
 	}
-	return stockPrice.Price, nil
+	return closes, nil
 }
 
-func roundToDecimal(value float64, decimals int) float64 {
-	multiplier := 1.0
-	for i := 0; i < decimals; i++ {
-		multiplier *= 10
+// GetDailyPrices returns imported end-of-day prices for a symbol with trade
+// dates within [from, to), oldest first
+func (s *StockPriceService) GetDailyPrices(symbol string, from, to time.Time) ([]models.DailyPrice, error) {
+	var prices []models.DailyPrice
+	if err := s.db.Where("stock_symbol = ? AND trade_date >= ? AND trade_date < ?",
+		symbol, from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Order("trade_date").
+		Find(&prices).Error; err != nil {
+		return nil, err
 	}
-	return float64(int(value*multiplier+0.5)) / multiplier
+	return prices, nil
 }
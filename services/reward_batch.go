@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"stocky/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// MaxBatchRewards caps the rows accepted by a single batch request
+const MaxBatchRewards = 10000
+
+var (
+	ErrInvalidBatch    = errors.New("invalid reward batch")
+	errBatchRolledBack = errors.New("batch rolled back")
+	requiredCSVColumns = []string{"user_id", "stock_symbol"}
+)
+
+// BatchRewardRow is one row of a batch: a reward request, or the reason the
+// row could not be parsed into one
+type BatchRewardRow struct {
+	Request models.RewardRequest
+	Err     error
+}
+
+// ParseRewardCSV reads rewards from CSV with a header row naming the
+// columns user_id, stock_symbol, quantity or amount_inr, and optionally
+// rewarded_at (RFC3339), idempotency_key and campaign_id. Bad values fail only their row.
+func ParseRewardCSV(r io.Reader) ([]BatchRewardRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: CSV is empty", ErrInvalidBatch)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBatch, err)
+	}
+
+	index := make(map[string]int)
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredCSVColumns {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("%w: CSV header must include %s", ErrInvalidBatch, strings.Join(requiredCSVColumns, ", "))
+		}
+	}
+	_, hasQuantity := index["quantity"]
+	_, hasAmount := index["amount_inr"]
+	if !hasQuantity && !hasAmount {
+		return nil, fmt.Errorf("%w: CSV header must include quantity or amount_inr", ErrInvalidBatch)
+	}
+
+	var rows []BatchRewardRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBatch, err)
+		}
+		if len(rows) == MaxBatchRewards {
+			return nil, fmt.Errorf("%w: at most %d rows are allowed", ErrInvalidBatch, MaxBatchRewards)
+		}
+
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := BatchRewardRow{Request: models.RewardRequest{
+			UserID:         field("user_id"),
+			StockSymbol:    field("stock_symbol"),
+			IdempotencyKey: field("idempotency_key"),
+		}}
+		if value := field("quantity"); value != "" {
+			if row.Request.Quantity, err = decimal.NewFromString(value); err != nil {
+				row.Err = fmt.Errorf("%w: quantity %q is not a number", ErrInvalidReward, value)
+			}
+		}
+		if value := field("amount_inr"); value != "" && row.Err == nil {
+			amount, err := decimal.NewFromString(value)
+			if err != nil {
+				row.Err = fmt.Errorf("%w: amount_inr %q is not a number", ErrInvalidReward, value)
+			}
+			row.Request.AmountINR = decimal.NewNullDecimal(amount)
+		}
+		if value := field("rewarded_at"); value != "" && row.Err == nil {
+			if row.Request.RewardedAt, err = time.Parse(time.RFC3339, value); err != nil {
+				row.Err = fmt.Errorf("%w: rewarded_at %q is not an RFC3339 time", ErrInvalidReward, value)
+			}
+		}
+		if value := field("campaign_id"); value != "" && row.Err == nil {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				row.Err = fmt.Errorf("%w: campaign_id %q is not a number", ErrInvalidReward, value)
+			}
+			row.Request.CampaignID = &id
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: CSV has no rows", ErrInvalidBatch)
+	}
+	return rows, nil
+}
+
+// ParseRewardJSON reads rewards from a JSON array of reward requests. Each
+// element is decoded on its own, so a bad value fails only its row.
+func ParseRewardJSON(r io.Reader) ([]BatchRewardRow, error) {
+	var elements []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&elements); err != nil {
+		return nil, fmt.Errorf("%w: body must be a JSON array of rewards: %v", ErrInvalidBatch, err)
+	}
+	if len(elements) > MaxBatchRewards {
+		return nil, fmt.Errorf("%w: at most %d rows are allowed", ErrInvalidBatch, MaxBatchRewards)
+	}
+
+	rows := make([]BatchRewardRow, len(elements))
+	for i, element := range elements {
+		if err := json.Unmarshal(element, &rows[i].Request); err != nil {
+			rows[i] = BatchRewardRow{Err: fmt.Errorf("%w: %v", ErrInvalidReward, err)}
+		}
+	}
+	return rows, nil
+}
+
+// CreateBatch creates each row exactly as Create would, with the row's own
+// idempotency key. In all-or-nothing mode every row runs inside one
+// transaction and a single failure rolls back the whole batch; in
+// best-effort mode each row commits on its own.
+func (s *RewardService) CreateBatch(ctx context.Context, rows []BatchRewardRow, mode string) (*models.BatchRewardResponse, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: no rewards given", ErrInvalidBatch)
+	}
+	if len(rows) > MaxBatchRewards {
+		return nil, fmt.Errorf("%w: at most %d rows are allowed", ErrInvalidBatch, MaxBatchRewards)
+	}
+
+	response := &models.BatchRewardResponse{
+		Mode:    mode,
+		Total:   len(rows),
+		Results: make([]models.BatchRewardResult, len(rows)),
+	}
+
+	createRows := func(db *gorm.DB) {
+		for i, row := range rows {
+			result := &response.Results[i]
+			result.Row = i + 1
+			result.IdempotencyKey = row.Request.IdempotencyKey
+
+			err := row.Err
+			if err == nil {
+				var reward *models.RewardResponse
+				var replayed bool
+				reward, replayed, err = s.create(db, row.Request)
+				if err == nil {
+					result.Reward = reward
+					result.Status = models.BatchRowCreated
+					if replayed {
+						result.Status = models.BatchRowReplayed
+					}
+					continue
+				}
+			}
+			result.Status = models.BatchRowFailed
+			result.Error = err.Error()
+			result.Err = err
+			response.Failed++
+		}
+	}
+
+	switch mode {
+	case models.BatchModeAllOrNothing:
+		// Each row's own transaction becomes a savepoint inside this one
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			createRows(tx)
+			if response.Failed > 0 {
+				return errBatchRolledBack
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errBatchRolledBack) {
+			return nil, err
+		}
+		response.Committed = err == nil
+	case models.BatchModeBestEffort:
+		createRows(s.db.WithContext(ctx))
+		response.Committed = true
+	default:
+		return nil, fmt.Errorf("%w: mode must be %s or %s", ErrInvalidBatch, models.BatchModeAllOrNothing, models.BatchModeBestEffort)
+	}
+
+	for i := range response.Results {
+		result := &response.Results[i]
+		switch {
+		case result.Status == models.BatchRowFailed:
+		case !response.Committed:
+			result.Status = models.BatchRowRolledBack
+			result.Reward = nil
+		case result.Status == models.BatchRowReplayed:
+			response.Replayed++
+		default:
+			response.Created++
+		}
+	}
+
+	logrus.Infof("Processed %s reward batch of %d rows: %d created, %d replayed, %d failed",
+		mode, response.Total, response.Created, response.Replayed, response.Failed)
+	return response, nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseRewardJSON(t *testing.T) {
+	body := `[
+		{"user_id": "u1", "stock_symbol": "TCS", "quantity": "2"},
+		{"user_id": "u2", "stock_symbol": "TCS", "quantity": {"bad": true}},
+		{"user_id": 3, "stock_symbol": "INFY", "quantity": "1"},
+		{"user_id": "u4", "stock_symbol": "INFY", "amount_inr": "500", "idempotency_key": "k4"}
+	]`
+	rows, err := ParseRewardJSON(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("ParseRewardJSON: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("got %d rows, want 4", len(rows))
+	}
+
+	for i, wantErr := range []bool{false, true, true, false} {
+		if (rows[i].Err != nil) != wantErr {
+			t.Errorf("row %d error = %v, want error %v", i+1, rows[i].Err, wantErr)
+		}
+		if rows[i].Err != nil && !errors.Is(rows[i].Err, ErrInvalidReward) {
+			t.Errorf("row %d error = %v, want ErrInvalidReward", i+1, rows[i].Err)
+		}
+	}
+	if rows[0].Request.UserID != "u1" || rows[0].Request.Quantity.String() != "2" {
+		t.Errorf("row 1 = %+v", rows[0].Request)
+	}
+	if !rows[3].Request.AmountINR.Valid || rows[3].Request.AmountINR.Decimal.String() != "500" || rows[3].Request.IdempotencyKey != "k4" {
+		t.Errorf("row 4 = %+v", rows[3].Request)
+	}
+}
+
+func TestParseRewardJSONInvalidBody(t *testing.T) {
+	for _, body := range []string{``, `{"user_id": "u1"}`, `[{"user_id": "u1"}`} {
+		if _, err := ParseRewardJSON(strings.NewReader(body)); !errors.Is(err, ErrInvalidBatch) {
+			t.Errorf("ParseRewardJSON(%q) error = %v, want ErrInvalidBatch", body, err)
+		}
+	}
+}
@@ -0,0 +1,424 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"stocky/audit"
+	"stocky/models"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidReward         = errors.New("invalid reward")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key was already used with a different request")
+	ErrRewardAlreadyReversed = errors.New("reward already reversed")
+	ErrPriceUnavailable      = errors.New("price unavailable")
+	ErrPriceStale            = errors.New("price is stale")
+
+	errDuplicateIdempotencyKey = errors.New("duplicate idempotency key")
+)
+
+type RewardService struct {
+	db         *gorm.DB
+	prices     *StockPriceService
+	fees       *FeeService
+	ledger     *LedgerService
+	campaigns  *CampaignService
+	securities *SecurityService
+}
+
+func NewRewardService(db *gorm.DB, prices *StockPriceService, fees *FeeService, ledger *LedgerService, campaigns *CampaignService, securities *SecurityService) *RewardService {
+	return &RewardService{db: db, prices: prices, fees: fees, ledger: ledger, campaigns: campaigns, securities: securities}
+}
+
+// RewardFingerprint hashes the fields that define a reward request, as sent
+// by the client, so a reused idempotency key can be told apart from a retry
+func RewardFingerprint(req models.RewardRequest) string {
+	rewardedAt := ""
+	if !req.RewardedAt.IsZero() {
+		rewardedAt = req.RewardedAt.UTC().Format(time.RFC3339Nano)
+	}
+	fields := []string{
+		req.UserID,
+		req.StockSymbol,
+		models.RoundQuantity(req.Quantity).String(),
+		rewardedAt,
+	}
+	// Only appended when set so fingerprints stored before these fields still match
+	if req.AmountINR.Valid {
+		fields = append(fields, "amount:"+models.RoundAmount(req.AmountINR.Decimal).String())
+	}
+	if req.CampaignID != nil {
+		fields = append(fields, fmt.Sprintf("campaign:%d", *req.CampaignID))
+	}
+	canonical, _ := json.Marshal(fields)
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// Create books a reward and its journal. A request repeating an earlier
+// idempotency key with the same payload returns the original response with
+// replayed set; the same key with a different payload fails with
+// ErrIdempotencyKeyReused.
+func (s *RewardService) Create(ctx context.Context, req models.RewardRequest) (*models.RewardResponse, bool, error) {
+	return s.create(s.db.WithContext(ctx), req)
+}
+
+// create runs Create against db, which may itself be an open transaction
+func (s *RewardService) create(db *gorm.DB, req models.RewardRequest) (*models.RewardResponse, bool, error) {
+	if req.UserID == "" || req.StockSymbol == "" {
+		return nil, false, fmt.Errorf("%w: user_id and stock_symbol are required", ErrInvalidReward)
+	}
+	if req.AmountINR.Valid {
+		if !req.Quantity.IsZero() {
+			return nil, false, fmt.Errorf("%w: give either quantity or amount_inr, not both", ErrInvalidReward)
+		}
+		req.AmountINR.Decimal = models.RoundAmount(req.AmountINR.Decimal)
+		if !req.AmountINR.Decimal.IsPositive() {
+			return nil, false, fmt.Errorf("%w: amount_inr must be at least 0.01", ErrInvalidReward)
+		}
+	} else if !req.Quantity.IsPositive() {
+		return nil, false, fmt.Errorf("%w: quantity must be greater than 0", ErrInvalidReward)
+	}
+	req.Quantity = models.RoundQuantity(req.Quantity)
+	fingerprint := RewardFingerprint(req)
+
+	// Set default timestamp if not provided
+	if req.RewardedAt.IsZero() {
+		req.RewardedAt = time.Now()
+	}
+
+	// Without a client key there is nothing to deduplicate against. A retry
+	// of a reward already booked replays it before any check that could now
+	// refuse it, such as a suspended symbol or a stale price.
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = "auto-" + randomHex(16)
+	} else if response, replayed, err := s.replay(db, req.IdempotencyKey, fingerprint); !errors.Is(err, gorm.ErrRecordNotFound) {
+		return response, replayed, err
+	}
+
+	// Only active symbols from the securities master can be rewarded
+	security, err := s.securities.Tradable(req.StockSymbol)
+	if err != nil {
+		return nil, false, err
+	}
+	if !req.AmountINR.Valid && !req.Quantity.Mod(security.LotSize).IsZero() {
+		return nil, false, fmt.Errorf("%w: %s quantity must be a multiple of %s", ErrInvalidReward, req.StockSymbol, security.LotSize)
+	}
+
+	// Rewards are only booked at a fresh price
+	quote, err := s.prices.GetQuote(req.StockSymbol)
+	if err != nil {
+		logrus.Errorf("Failed to get stock price: %v", err)
+		return nil, false, fmt.Errorf("%w: %s", ErrPriceUnavailable, req.StockSymbol)
+	}
+	if quote.Stale {
+		return nil, false, fmt.Errorf("%w: latest %s price is from %s", ErrPriceStale, req.StockSymbol, quote.AsOf.Format(time.RFC3339))
+	}
+	price := quote.Price
+	if !price.IsPositive() {
+		return nil, false, fmt.Errorf("%w: %s has no positive price", ErrPriceUnavailable, req.StockSymbol)
+	}
+
+	// Rupee rewards buy as many shares as the amount covers at the current
+	// price; whatever the truncated quantity doesn't buy is the residue
+	residue := decimal.NullDecimal{}
+	if req.AmountINR.Valid {
+		req.Quantity = roundDownToLot(models.QuantityForAmount(req.AmountINR.Decimal, price), security.LotSize)
+		if !req.Quantity.IsPositive() {
+			return nil, false, fmt.Errorf("%w: amount_inr %s buys less than one lot of %s %s at %s", ErrInvalidReward, req.AmountINR.Decimal, security.LotSize, req.StockSymbol, price)
+		}
+	}
+
+	// Calculate fees from the schedule in effect when the reward was granted
+	stockValue := models.RoundAmount(req.Quantity.Mul(price))
+	if req.AmountINR.Valid {
+		residue = decimal.NewNullDecimal(req.AmountINR.Decimal.Sub(stockValue))
+	}
+	fees, err := s.fees.Calculate(req.RewardedAt, stockValue)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to calculate fees: %w", err)
+	}
+
+	var response models.RewardResponse
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// The unique key decides the race: a concurrent request with the same
+		// key blocks here until this one commits, then inserts nothing
+		record := models.IdempotencyRecord{
+			Key:         req.IdempotencyKey,
+			Fingerprint: fingerprint,
+		}
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}},
+			DoNothing: true,
+		}).Create(&record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errDuplicateIdempotencyKey
+		}
+
+		if req.CampaignID != nil {
+			if err := s.campaigns.CheckReward(tx, *req.CampaignID, req, stockValue, fees); err != nil {
+				return err
+			}
+		}
+
+		if err := checkBackdatedReward(tx, req.StockSymbol, req.RewardedAt); err != nil {
+			return err
+		}
+
+		reward := models.StockReward{
+			UserID:         req.UserID,
+			StockSymbol:    req.StockSymbol,
+			Quantity:       req.Quantity,
+			RewardedAt:     req.RewardedAt,
+			IdempotencyKey: req.IdempotencyKey,
+			CampaignID:     req.CampaignID,
+			AmountINR:      req.AmountINR,
+		}
+		if err := tx.Create(&reward).Error; err != nil {
+			return fmt.Errorf("failed to create reward: %w", err)
+		}
+
+		journal := rewardJournal(reward, price, stockValue, residue, fees)
+		if err := s.ledger.Post(tx, &journal); err != nil {
+			return fmt.Errorf("failed to post reward journal: %w", err)
+		}
+
+		response = models.RewardResponse{
+			ID:           reward.ID,
+			UserID:       reward.UserID,
+			StockSymbol:  reward.StockSymbol,
+			Quantity:     reward.Quantity,
+			AmountINR:    reward.AmountINR,
+			Residue:      residue,
+			RewardedAt:   reward.RewardedAt,
+			CurrentPrice: price,
+			CurrentValue: stockValue,
+			PriceAsOf:    &quote.AsOf,
+			Fees:         fees,
+			CampaignID:   reward.CampaignID,
+		}
+
+		if err := audit.Record(tx, models.AuditRewardCreate, "reward", reward.ID, nil, response); err != nil {
+			return err
+		}
+
+		body, err := json.Marshal(response)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&record).Updates(map[string]interface{}{
+			"reward_id":     reward.ID,
+			"response_body": string(body),
+		}).Error
+	})
+	if errors.Is(err, errDuplicateIdempotencyKey) {
+		return s.replay(db, req.IdempotencyKey, fingerprint)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	logrus.Infof("Created reward for user %s: %s shares of %s", req.UserID, req.Quantity, req.StockSymbol)
+	return &response, false, nil
+}
+
+// rewardJournal builds the balanced journal for a reward: stock bought for
+// the user and each fee component, all paid from company cash. The stock
+// entry records the unit price and, for rupee rewards, the residue.
+func rewardJournal(reward models.StockReward, price, stockValue decimal.Decimal, residue decimal.NullDecimal, fees *models.FeeBreakdown) models.Journal {
+	entries := []models.LedgerEntry{
+		{
+			AccountCode: models.AccountUserStockHoldings,
+			RewardID:    &reward.ID,
+			UserID:      reward.UserID,
+			EntryType:   models.EntryTypeStockCredit,
+			StockSymbol: reward.StockSymbol,
+			Quantity:    reward.Quantity,
+			Amount:      stockValue,
+			Price:       decimal.NewNullDecimal(price),
+			Residue:     residue,
+			Description: fmt.Sprintf("Stock reward credited to user %s", reward.UserID),
+		},
+		{
+			AccountCode: models.AccountCompanyCash,
+			RewardID:    &reward.ID,
+			UserID:      reward.UserID,
+			EntryType:   models.EntryTypeCashDebit,
+			StockSymbol: reward.StockSymbol,
+			Quantity:    decimal.Zero,
+			Amount:      stockValue.Neg(),
+			Description: "Company cash outflow for stock purchase",
+		},
+	}
+
+	// One FEE_EXPENSE / FEE_DEBIT pair per fee component
+	for _, item := range fees.Items {
+		description := fmt.Sprintf("%s (fee schedule %d)", item.Name, fees.ScheduleID)
+		entries = append(entries,
+			models.LedgerEntry{
+				AccountCode: FeeAccount(item.Code),
+				RewardID:    &reward.ID,
+				UserID:      reward.UserID,
+				EntryType:   models.EntryTypeFeeExpense,
+				StockSymbol: reward.StockSymbol,
+				FeeCode:     item.Code,
+				Quantity:    decimal.Zero,
+				Amount:      item.Amount,
+				Description: description,
+			},
+			models.LedgerEntry{
+				AccountCode: models.AccountCompanyCash,
+				RewardID:    &reward.ID,
+				UserID:      reward.UserID,
+				EntryType:   models.EntryTypeFeeDebit,
+				StockSymbol: reward.StockSymbol,
+				FeeCode:     item.Code,
+				Quantity:    decimal.Zero,
+				Amount:      item.Amount.Neg(),
+				Description: description,
+			},
+		)
+	}
+
+	return models.Journal{
+		Reference:   fmt.Sprintf("reward:%d", reward.ID),
+		Description: fmt.Sprintf("Reward of %s %s to user %s", reward.Quantity, reward.StockSymbol, reward.UserID),
+		PostedAt:    reward.RewardedAt,
+		Entries:     entries,
+	}
+}
+
+// replay returns the stored response for an idempotency key already used
+func (s *RewardService) replay(db *gorm.DB, key, fingerprint string) (*models.RewardResponse, bool, error) {
+	var record models.IdempotencyRecord
+	if err := db.Where("key = ?", key).First(&record).Error; err != nil {
+		return nil, false, err
+	}
+
+	// Keys carried over from before fingerprints were stored can't be compared
+	if record.Fingerprint != "" && record.Fingerprint != fingerprint {
+		return nil, false, ErrIdempotencyKeyReused
+	}
+
+	var response models.RewardResponse
+	if record.ResponseBody != "" {
+		if err := json.Unmarshal([]byte(record.ResponseBody), &response); err != nil {
+			return nil, false, err
+		}
+		return &response, true, nil
+	}
+
+	var reward models.StockReward
+	if err := db.First(&reward, record.RewardID).Error; err != nil {
+		return nil, false, err
+	}
+	response = models.RewardResponse{
+		ID:          reward.ID,
+		UserID:      reward.UserID,
+		StockSymbol: reward.StockSymbol,
+		Quantity:    reward.Quantity,
+		AmountINR:   reward.AmountINR,
+		RewardedAt:  reward.RewardedAt,
+		CampaignID:  reward.CampaignID,
+	}
+	return &response, true, nil
+}
+
+// Reverse marks a reward reversed and posts a journal mirroring every
+// original entry for it. Returns gorm.ErrRecordNotFound for unknown rewards.
+func (s *RewardService) Reverse(ctx context.Context, rewardID int64, reason string) (*models.StockReward, error) {
+	var reward models.StockReward
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&reward, rewardID).Error; err != nil {
+			return err
+		}
+		if reward.ReversedAt != nil {
+			return ErrRewardAlreadyReversed
+		}
+		before := reward
+
+		var originals []models.LedgerEntry
+		if err := tx.Where("reward_id = ? AND reverses_id IS NULL", reward.ID).
+			Order("id").Find(&originals).Error; err != nil {
+			return err
+		}
+
+		journal := models.Journal{
+			Reference:   fmt.Sprintf("reward:%d:reversal", reward.ID),
+			Description: fmt.Sprintf("Reversal of reward %d: %s", reward.ID, reason),
+		}
+		for _, original := range originals {
+			journal.Entries = append(journal.Entries, models.LedgerEntry{
+				AccountCode: original.AccountCode,
+				RewardID:    &reward.ID,
+				UserID:      reward.UserID,
+				EntryType:   original.EntryType + models.ReversalSuffix,
+				StockSymbol: original.StockSymbol,
+				FeeCode:     original.FeeCode,
+				Quantity:    original.Quantity.Neg(),
+				Amount:      original.Amount.Neg(),
+				Price:       original.Price,
+				Residue:     negateNull(original.Residue),
+				Description: fmt.Sprintf("Reversal of entry %d: %s", original.ID, reason),
+				ReversesID:  &original.ID,
+			})
+		}
+		if len(journal.Entries) > 0 {
+			if err := s.ledger.Post(tx, &journal); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		reward.ReversedAt = &now
+		reward.ReversalReason = reason
+		if err := tx.Model(&reward).Updates(map[string]interface{}{
+			"reversed_at":     reward.ReversedAt,
+			"reversal_reason": reward.ReversalReason,
+		}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditRewardReverse, "reward", reward.ID, before, reward)
+	})
+	if err != nil {
+		return &reward, err
+	}
+
+	logrus.Infof("Reversed reward %d for user %s: %s", reward.ID, reward.UserID, reason)
+	return &reward, nil
+}
+
+// roundDownToLot truncates quantity to a whole number of lots
+func roundDownToLot(quantity, lotSize decimal.Decimal) decimal.Decimal {
+	return quantity.Div(lotSize).Floor().Mul(lotSize)
+}
+
+func negateNull(d decimal.NullDecimal) decimal.NullDecimal {
+	if d.Valid {
+		d.Decimal = d.Decimal.Neg()
+	}
+	return d
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"stocky/audit"
+	"stocky/models"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidSecurity     = errors.New("invalid security")
+	ErrSecurityNotFound    = errors.New("security not found")
+	ErrSecurityInUse       = errors.New("security has rewards")
+	ErrUnknownSymbol       = errors.New("unknown stock symbol")
+	ErrSymbolNotTradable   = errors.New("stock symbol is not active")
+	defaultLotSize         = decimal.New(1, -models.QuantityPlaces)
+	requiredSecurityFields = []string{"symbol", "isin", "name", "exchange"}
+)
+
+type SecurityService struct {
+	db *gorm.DB
+}
+
+func NewSecurityService(db *gorm.DB) *SecurityService {
+	return &SecurityService{db: db}
+}
+
+// Seed imports path into an empty securities master, then adds any symbol
+// already rewarded but missing from the master as SUSPENDED so existing
+// holdings keep their prices while an admin reviews it
+func (s *SecurityService) Seed(path string) error {
+	ctx := context.Background()
+	var count int64
+	if err := s.db.Model(&models.Security{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 && path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open securities file: %w", err)
+		}
+		defer f.Close()
+
+		result, err := s.ImportCSV(ctx, f)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Seeded %d securities from %s", result.Created, path)
+	}
+
+	var missing []string
+	if err := s.db.Model(&models.StockReward{}).
+		Distinct("stock_symbol").
+		Where("NOT EXISTS (SELECT 1 FROM securities s WHERE s.symbol = stock_rewards.stock_symbol)").
+		Order("stock_symbol").
+		Pluck("stock_symbol", &missing).Error; err != nil {
+		return fmt.Errorf("failed to find legacy symbols: %w", err)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, symbol := range missing {
+			security := models.Security{
+				Symbol:   symbol,
+				Name:     symbol,
+				Exchange: models.ExchangeNSE,
+				LotSize:  defaultLotSize,
+				Status:   models.SecurityStatusSuspended,
+			}
+			if err := tx.Create(&security).Error; err != nil {
+				return err
+			}
+			if err := audit.Record(tx, models.AuditSecurityCreate, "security", security.Symbol, nil, security); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add legacy symbols: %w", err)
+	}
+	logrus.Warnf("Added %d rewarded symbols missing from the securities master as %s", len(missing), models.SecurityStatusSuspended)
+	return nil
+}
+
+// Create validates and stores a new security
+func (s *SecurityService) Create(ctx context.Context, security *models.Security) error {
+	if err := validateSecurity(security); err != nil {
+		return err
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(security).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditSecurityCreate, "security", security.Symbol, nil, security)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create security: %w", err)
+	}
+	return nil
+}
+
+// Update replaces the editable fields of the security with the given symbol
+func (s *SecurityService) Update(ctx context.Context, symbol string, changes models.Security) (*models.Security, error) {
+	security, err := s.Get(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	changes.Symbol = security.Symbol
+	if err := validateSecurity(&changes); err != nil {
+		return nil, err
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before models.Security
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("symbol = ?", security.Symbol).First(&before).Error; err != nil {
+			return err
+		}
+		after := before
+		after.ISIN, after.Name, after.Exchange, after.LotSize, after.Status =
+			changes.ISIN, changes.Name, changes.Exchange, changes.LotSize, changes.Status
+		if err := tx.Model(&after).Updates(map[string]interface{}{
+			"isin":     after.ISIN,
+			"name":     after.Name,
+			"exchange": after.Exchange,
+			"lot_size": after.LotSize,
+			"status":   after.Status,
+		}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditSecurityUpdate, "security", after.Symbol, before, after)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update security: %w", err)
+	}
+	return s.Get(symbol)
+}
+
+// Delete removes a security that has never been rewarded. Rewarded
+// securities should be delisted instead.
+func (s *SecurityService) Delete(ctx context.Context, symbol string) error {
+	security, err := s.Get(symbol)
+	if err != nil {
+		return err
+	}
+
+	var rewards int64
+	if err := s.db.Model(&models.StockReward{}).Where("stock_symbol = ?", security.Symbol).Count(&rewards).Error; err != nil {
+		return err
+	}
+	if rewards > 0 {
+		return fmt.Errorf("%w: %s has %d rewards, delist it instead", ErrSecurityInUse, security.Symbol, rewards)
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(security).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, models.AuditSecurityDelete, "security", security.Symbol, security, nil)
+	})
+}
+
+// List returns securities ordered by symbol, optionally filtered by status
+func (s *SecurityService) List(status string) ([]models.Security, error) {
+	query := s.db.Order("symbol")
+	if status != "" {
+		query = query.Where("status = ?", strings.ToUpper(status))
+	}
+
+	var securities []models.Security
+	if err := query.Find(&securities).Error; err != nil {
+		return nil, err
+	}
+	return securities, nil
+}
+
+// Get returns the security with the given symbol
+func (s *SecurityService) Get(symbol string) (*models.Security, error) {
+	var security models.Security
+	err := s.db.Where("symbol = ?", strings.ToUpper(symbol)).First(&security).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrSecurityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &security, nil
+}
+
+// Tradable returns the security for a symbol that can be rewarded, or
+// ErrUnknownSymbol / ErrSymbolNotTradable
+func (s *SecurityService) Tradable(symbol string) (*models.Security, error) {
+	var security models.Security
+	err := s.db.Where("symbol = ?", symbol).First(&security).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("%w: %q is not in the securities master", ErrUnknownSymbol, symbol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if security.Status != models.SecurityStatusActive {
+		return nil, fmt.Errorf("%w: %s is %s", ErrSymbolNotTradable, symbol, strings.ToLower(security.Status))
+	}
+	return &security, nil
+}
+
+// ImportCSV upserts securities from CSV with a header row naming the
+// columns symbol, isin, name, exchange and optionally lot_size and status.
+// The whole file is rejected if any row is invalid.
+func (s *SecurityService) ImportCSV(ctx context.Context, r io.Reader) (*models.SecurityImportResponse, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot read CSV header: %v", ErrInvalidSecurity, err)
+	}
+	index := make(map[string]int)
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredSecurityFields {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("%w: CSV header must include %s", ErrInvalidSecurity, strings.Join(requiredSecurityFields, ", "))
+		}
+	}
+
+	var securities []models.Security
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSecurity, err)
+		}
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		security := models.Security{
+			Symbol:   field("symbol"),
+			ISIN:     field("isin"),
+			Name:     field("name"),
+			Exchange: field("exchange"),
+			Status:   field("status"),
+		}
+		if value := field("lot_size"); value != "" {
+			if security.LotSize, err = decimal.NewFromString(value); err != nil {
+				return nil, fmt.Errorf("%w: line %d: lot_size %q is not a number", ErrInvalidSecurity, line, value)
+			}
+		}
+		if err := validateSecurity(&security); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		securities = append(securities, security)
+	}
+	if len(securities) == 0 {
+		return nil, fmt.Errorf("%w: CSV has no rows", ErrInvalidSecurity)
+	}
+
+	result := &models.SecurityImportResponse{}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range securities {
+			var existing []models.Security
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("symbol = ?", securities[i].Symbol).Find(&existing).Error; err != nil {
+				return err
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "symbol"}},
+				DoUpdates: clause.AssignmentColumns([]string{"isin", "name", "exchange", "lot_size", "status", "updated_at"}),
+			}).Create(&securities[i]).Error; err != nil {
+				return fmt.Errorf("failed to import %s: %w", securities[i].Symbol, err)
+			}
+
+			action := models.AuditSecurityCreate
+			var before interface{}
+			if len(existing) > 0 {
+				action, before = models.AuditSecurityUpdate, existing[0]
+				result.Updated++
+			} else {
+				result.Created++
+			}
+			if err := audit.Record(tx, action, "security", securities[i].Symbol, before, securities[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// validateSecurity normalises a security and checks its fields
+func validateSecurity(security *models.Security) error {
+	security.Symbol = strings.ToUpper(strings.TrimSpace(security.Symbol))
+	security.ISIN = strings.ToUpper(strings.TrimSpace(security.ISIN))
+	security.Exchange = strings.ToUpper(strings.TrimSpace(security.Exchange))
+	security.Status = strings.ToUpper(strings.TrimSpace(security.Status))
+	if security.Status == "" {
+		security.Status = models.SecurityStatusActive
+	}
+	if security.LotSize.IsZero() {
+		security.LotSize = defaultLotSize
+	}
+
+	switch {
+	case security.Symbol == "" || len(security.Symbol) > 20:
+		return fmt.Errorf("%w: symbol must be 1-20 characters", ErrInvalidSecurity)
+	case len(security.ISIN) != 12:
+		return fmt.Errorf("%w: %s: ISIN must be 12 characters", ErrInvalidSecurity, security.Symbol)
+	case strings.TrimSpace(security.Name) == "":
+		return fmt.Errorf("%w: %s: name is required", ErrInvalidSecurity, security.Symbol)
+	case security.Exchange != models.ExchangeNSE && security.Exchange != models.ExchangeBSE:
+		return fmt.Errorf("%w: %s: exchange must be NSE or BSE", ErrInvalidSecurity, security.Symbol)
+	case !security.LotSize.IsPositive() || !security.LotSize.Equal(models.RoundQuantity(security.LotSize)):
+		return fmt.Errorf("%w: %s: lot_size must be positive with at most 6 decimals", ErrInvalidSecurity, security.Symbol)
+	}
+	switch security.Status {
+	case models.SecurityStatusActive, models.SecurityStatusSuspended, models.SecurityStatusDelisted:
+	default:
+		return fmt.Errorf("%w: %s: status must be ACTIVE, SUSPENDED or DELISTED", ErrInvalidSecurity, security.Symbol)
+	}
+	return nil
+}